@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func applyConfigFile(fs *flag.FlagSet, path string) error {
+
+	// a JSON file keeps a reproducible WORM policy in version control, e.g. {"b": "archive", "mode": "compliance", "retention-days": 30}
+	// its keys are the flag names of the subcommand, and an explicit flag overrides the value of the file
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	// the numbers keep their literal form, e.g. a part size in bytes
+	var values map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err = dec.Decode(&values)
+	if err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	for name, value := range values {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("the config file %s sets %q, which is no flag of the command %s", path, name, fs.Name())
+		}
+		if explicit[name] {
+			continue
+		}
+		// a list sets a repeatable flag like -meta once per element
+		list, ok := value.([]interface{})
+		if !ok {
+			list = []interface{}{value}
+		}
+		for _, v := range list {
+			err = fs.Set(name, fmt.Sprint(v))
+			if err != nil {
+				return fmt.Errorf("the config file %s sets an invalid %q: %w", path, name, err)
+			}
+		}
+	}
+	return nil
+
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+)
+
+func newLogger(w io.Writer, errW io.Writer, verbose bool, quiet bool) *slog.Logger {
+
+	// only key milestones and errors are logged, unless the debug output is requested
+	// the quiet output of scripts only keeps the errors, the final error of the run is printed by main anyway
+
+	level := slog.LevelInfo
+	if verbose {
+		level = slog.LevelDebug
+	} else if quiet {
+		level = slog.LevelError
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	return slog.New(splitHandler{out: slog.NewTextHandler(w, opts), err: slog.NewTextHandler(errW, opts)})
+
+}
+
+// splitHandler writes the warnings and errors to stderr and the other messages to stdout,
+// so the output can be captured apart from the problems, e.g. the JSON result with 2>errors.log
+type splitHandler struct {
+	out slog.Handler
+	err slog.Handler
+}
+
+func (h splitHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.out.Enabled(ctx, level)
+}
+
+func (h splitHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelWarn {
+		return h.err.Handle(ctx, r)
+	}
+	return h.out.Handle(ctx, r)
+}
+
+func (h splitHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return splitHandler{out: h.out.WithAttrs(attrs), err: h.err.WithAttrs(attrs)}
+}
+
+func (h splitHandler) WithGroup(name string) slog.Handler {
+	return splitHandler{out: h.out.WithGroup(name), err: h.err.WithGroup(name)}
+}
@@ -1,185 +1,194 @@
-package main
-
-import (
-	"bytes"
-	"context"
-	"crypto/md5"
-	"encoding/base64"
-	"flag"
-	"fmt"
-	"io"
-	"log"
-	"net/http"
-	"os"
-	"time"
-
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/aws-sdk-go-v2/service/s3/types"
-)
-
-func main() {
-
-	bucket := flag.String("b", "", "The name of the bucket")
-	filename := flag.String("f", "", "The file to upload")
-	// parse the input arguments
-	flag.Parse()
-
-	// check the input arguments
-	if *bucket == "" || *filename == "" {
-		fmt.Println("You must supply a bucket name [-b BUCKET] and a filename [-f FILENAME]")
-		return
-	}
-
-	// load the AWS configuration with the environment variables
-	cfg, err := config.LoadDefaultConfig(context.TODO())
-	if err != nil {
-		fmt.Println("AWS configuration error, " + err.Error())
-		return
-	}
-	// set your appropriate region
-	cfg.Region = "us-east-1"
-
-	// the service client for the next actions
-	client := s3.NewFromConfig(cfg)
-
-	// prepare the input for the new bucket with Object Locking
-	inputCB := &s3.CreateBucketInput{
-		Bucket:                     bucket,
-		ObjectLockEnabledForBucket: true, // enable Object Locking for WORM / archiving purposes
-	}
-
-	// create the bucket with Object Lock
-	_, err = client.CreateBucket(context.TODO(), inputCB)
-	if err != nil {
-		fmt.Printf("Could not create bucket %s \n" + *bucket)
-		fmt.Println(err.Error())
-	} else {
-		fmt.Printf("%s bucket created!!! \n", *bucket)
-	}
-
-	// create the input for the default retention period - here: *** GOVERNANCE mode for 2 days ***
-	inputPOLC := &s3.PutObjectLockConfigurationInput{
-		Bucket: bucket,
-		ObjectLockConfiguration: &types.ObjectLockConfiguration{ObjectLockEnabled: types.ObjectLockEnabledEnabled,
-			Rule: &types.ObjectLockRule{DefaultRetention: &types.DefaultRetention{Mode: types.ObjectLockRetentionModeGovernance, Days: 2}}},
-	}
-
-	// put the default retention period on the bucket
-	_, err = client.PutObjectLockConfiguration(context.TODO(), inputPOLC)
-	if err != nil {
-		fmt.Println("PutObjectLockConfiguration - error: ")
-		fmt.Println(err.Error())
-	} else {
-		fmt.Println("PutObjectLockConfiguration - success!")
-	}
-
-	// now prepare the request for the Object Lock configuration
-	inputGOLC := &s3.GetObjectLockConfigurationInput{
-		Bucket: bucket,
-	}
-
-	// request the Object Lock settings
-	out, err := client.GetObjectLockConfiguration(context.TODO(), inputGOLC)
-	if err != nil {
-		fmt.Println("GetObjectLockConfiguration - error: ")
-		fmt.Println(err.Error())
-	} else {
-		// print the settings
-		fmt.Println("ObjectLockEnabled:", out.ObjectLockConfiguration.ObjectLockEnabled)
-		if out.ObjectLockConfiguration.Rule != nil {
-			fmt.Println("DefaultRetention.Mode:", out.ObjectLockConfiguration.Rule.DefaultRetention.Mode)
-			fmt.Println("DefaultRetention.Days:", out.ObjectLockConfiguration.Rule.DefaultRetention.Days)
-		} else {
-			fmt.Println(" but there is NO ObjectLockConfiguration.Rule <nil>")
-		}
-	}
-
-	// prepare the upload of the file
-	file, err := os.Open(*filename)
-	if err != nil {
-		fmt.Println("Unable to open file " + *filename)
-		return
-	}
-	defer file.Close()
-
-	// Get file size and read the file content into a buffer
-	fileInfo, _ := file.Stat()
-	var size int64 = fileInfo.Size()
-	buffer := make([]byte, size)
-	file.Read(buffer)
-
-	// calculate a future date for the retention period of 1 day
-	mtime := time.Now().UTC().Local()
-	rt := mtime.AddDate(0, 0, 1)
-
-	// determine the content type of your S3 object - file to be uploaded
-	ct := http.DetectContentType(buffer)
-
-	// create a md5hash to verify the content for the AWS file upload
-	md5h := getMD5Hash(*filename)
-	if md5h == "" {
-		fmt.Println("no md5hash possible for:" + *filename)
-		return
-	}
-
-	// upload the file into the bucket - an object with the appropriate parameters
-	_, err = client.PutObject(context.TODO(), &s3.PutObjectInput{
-		Bucket:                    bucket,
-		Key:                       filename,
-		Body:                      bytes.NewReader(buffer),
-		ContentLength:             size,
-		ContentType:               &ct,
-		ContentMD5:                &md5h,
-		ObjectLockMode:            types.ObjectLockModeCompliance,
-		ObjectLockRetainUntilDate: &rt,
-	})
-	if err != nil {
-		fmt.Println("error:", err)
-	} else {
-		fmt.Printf("Putting of object %s into bucket %s has succeeded! \n", *filename, *bucket)
-	}
-
-	// prepare the request for existence of object in bucket
-	inputHO := &s3.HeadObjectInput{
-		Bucket: bucket,
-		Key:    filename,
-	}
-	// perform the request for existence of object in bucket
-	outHO, err := client.HeadObject(context.TODO(), inputHO)
-	if err != nil {
-		fmt.Printf("NO - object: %s in Bucket: %s does NOT exist! \n", *filename, *bucket)
-	} else {
-		fmt.Printf("YES - object: %s in Bucket: %s exists! \n", *filename, *bucket)
-		fmt.Println("ObjectLockMode:", outHO.ObjectLockMode)
-		fmt.Println("ObjectLockRetainUntilDate:", outHO.ObjectLockRetainUntilDate.Local())
-	}
-
-}
-
-func getMD5Hash(filename string) (hash string) {
-
-	// calculate the md5hash value for this file
-
-	if filename == "" {
-		return ""
-	}
-	file, err := os.Open(filename)
-	if err != nil {
-		return ""
-	}
-	defer file.Close()
-
-	hasher := md5.New()
-	_, err = io.Copy(hasher, file)
-	if err != nil {
-		log.Fatal(err)
-		return ""
-	}
-
-	sum := hasher.Sum(nil)
-
-	// the hash value must be base64 encoded to be accepted by AWS
-	return (base64.StdEncoding.EncodeToString(sum))
-
-}
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/MIRIBO4630/goS3ObjectLockTest/objectlock"
+	"golang.org/x/term"
+)
+
+// the exit codes of a failed run, so callers can tell an unsupported endpoint from a real failure
+const (
+	exitFailure     = 1
+	exitUnsupported = 3
+	exitThrottled   = 4
+	exitCancelled   = 130
+)
+
+// errCancelled marks a run interrupted with Ctrl-C
+var errCancelled = errors.New("cancelled")
+
+// errThrottled marks a run that failed because S3 still throttled the requests on the last attempt
+var errThrottled = errors.New("S3 throttles the requests - retry with a lower concurrency [-concurrency WORKERS] [-upload-concurrency PARTS]")
+
+// cliOptions are the flags of the command line itself, callers of objectlock.Run handle their output and duration
+type cliOptions struct {
+	Timeout     time.Duration
+	Quiet       bool
+	JSONOutput  bool
+	ConfigFile  string
+	SummaryFile string
+}
+
+func (o *cliOptions) flags(fs *flag.FlagSet) {
+
+	fs.DurationVar(&o.Timeout, "timeout", 30*time.Second, "The maximum duration of the whole run, 0 disables the timeout")
+	fs.BoolVar(&o.Quiet, "quiet", false, "Suppress all messages but the errors on stderr, e.g. for shell scripts")
+	fs.BoolVar(&o.JSONOutput, "json", false, "Print a single JSON object describing the run instead of the messages")
+	fs.StringVar(&o.ConfigFile, "config", "", "A JSON file with the flag values of the run, explicit flags override its values")
+	fs.StringVar(&o.SummaryFile, "summary-file", "", "A file to write the JSON result of the run to, with its time and AWS account - e.g. for an audit trail")
+
+}
+
+func main() {
+
+	// a failed run must be visible to scripts and CI by the exit code
+	// the steps return their wrapped errors, so the failure is printed only once here - on stderr, apart from the output
+	if err := run(os.Args[1:]); err != nil {
+		slog.New(slog.NewTextHandler(os.Stderr, nil)).Error(err.Error(), "error", objectlock.ErrorValue(err))
+		os.Exit(exitCode(err))
+	}
+
+}
+
+func exitCode(err error) int {
+
+	// map the cause of the failed run to its exit code
+
+	if errors.Is(err, errCancelled) {
+		return exitCancelled
+	}
+	if errors.Is(err, objectlock.ErrObjectLockUnsupported) {
+		return exitUnsupported
+	}
+	if errors.Is(err, errThrottled) {
+		return exitThrottled
+	}
+	return exitFailure
+
+}
+
+func run(args []string) error {
+
+	// the first argument selects the subcommand, without one the complete demo runs
+	name := "demo"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	cmd, ok := objectlock.Commands[name]
+	if !ok {
+		usage(os.Stderr)
+		return fmt.Errorf("unknown command %q", name)
+	}
+	return runCommand(name, cmd, args)
+
+}
+
+func runCommand(name string, cmd objectlock.Command, args []string) (err error) {
+
+	// parse the input arguments of the subcommand
+	cfg := &objectlock.Config{}
+	var opts cliOptions
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	opts.flags(fs)
+	cfg.ClientFlags(fs)
+	cmd.Flags(cfg, fs)
+	err = fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if opts.ConfigFile != "" {
+		err = applyConfigFile(fs, opts.ConfigFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the JSON object replaces the messages and is printed even for a failed run, like the summary file
+	res := &objectlock.Result{Bucket: cfg.Bucket}
+	var console io.Writer = os.Stdout
+	if opts.JSONOutput {
+		console = io.Discard
+	}
+	if opts.SummaryFile != "" {
+		now := time.Now().UTC()
+		res.Timestamp = &now
+		cfg.ResolveAccount = true
+	}
+	if opts.JSONOutput || opts.SummaryFile != "" {
+		defer func() {
+			if err != nil {
+				res.Error = err.Error()
+				res.ErrorCode, res.RequestID = objectlock.APIErrorDetails(err)
+			}
+			if opts.SummaryFile != "" {
+				err = errors.Join(err, writeSummaryFile(opts.SummaryFile, res))
+			}
+			if opts.JSONOutput {
+				writeJSON(os.Stdout, res)
+			}
+		}()
+	}
+	if opts.Quiet && cfg.Verbose {
+		return errors.New("the quiet output [-quiet] cannot be combined with the debug output [-verbose]")
+	}
+	// the HTTP traces are logged as messages, which the quiet and the JSON output drop
+	if cfg.DebugHTTP && (opts.Quiet || opts.JSONOutput) {
+		return errors.New("the HTTP traces [-debug-http] cannot be combined with the quiet output [-quiet] or the JSON output [-json]")
+	}
+	slog.SetDefault(newLogger(console, os.Stderr, cfg.Verbose, opts.Quiet))
+	cfg.Output = console
+	cfg.Color = !opts.JSONOutput && !opts.Quiet && term.IsTerminal(int(os.Stdout.Fd()))
+
+	ctx, cancel := newContext(opts.Timeout)
+	defer cancel()
+	err = cmd.Run(ctx, cfg, res)
+
+	// an expired timeout is a failure, an interrupt of the user is reported as such
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		return fmt.Errorf("%w: %w", errCancelled, err)
+	}
+	// the retries could not absorb the throttling, which is no failure of the Object Lock
+	if objectlock.IsThrottled(err) {
+		return fmt.Errorf("%w: %w", errThrottled, err)
+	}
+	return err
+
+}
+
+func usage(w io.Writer) {
+
+	// list the subcommands, the flags of each one are shown with: goS3ObjectLockTest COMMAND -h
+
+	fmt.Fprintln(w, "Usage: goS3ObjectLockTest [COMMAND] [FLAGS]")
+	fmt.Fprintln(w, "Commands:")
+	for _, name := range objectlock.CommandNames {
+		fmt.Fprintf(w, "  %-15s %s\n", name, objectlock.Commands[name].Summary)
+	}
+
+}
+
+func newContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+
+	// a hung network connection must not block the run forever
+	// Ctrl-C cancels the in-flight API calls, so a long upload stops cleanly instead of being killed
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		return ctx, func() { cancel(); stop() }
+	}
+	return ctx, stop
+
+}
@@ -0,0 +1,58 @@
+package objectlock
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// the APIs of the object verification
+const (
+	verifyHead       = "head"
+	verifyAttributes = "attributes"
+)
+
+func parseVerifyAPI(value string) (string, error) {
+
+	// HeadObject is the legacy verification, GetObjectAttributes also reports the parts of a multipart upload
+
+	switch api := strings.ToLower(value); api {
+	case verifyHead, verifyAttributes:
+		return api, nil
+	}
+	return "", fmt.Errorf("invalid verification API %q, expected head or attributes [-verify-api API]", value)
+
+}
+
+func describeAttributes(ctx context.Context, client S3API, bucket string, key string, versionID string) (*s3.GetObjectAttributesOutput, error) {
+
+	// log the integrity attributes of the object version - they replace the HeadObject of the verification,
+	// the Object Lock is verified with GetObjectRetention
+
+	outGA, err := getObjectAttributes(ctx, client, bucket, key, versionID)
+	if err != nil {
+		return nil, fmt.Errorf("get object attributes of %s: %w", key, err)
+	}
+	slog.Info("GetObjectAttributes - success!", "key", key, "ETag", aws.ToString(outGA.ETag), "ObjectSize", outGA.ObjectSize,
+		"StorageClass", outGA.StorageClass)
+	if c := outGA.Checksum; c != nil {
+		slog.Info("object checksum", "ChecksumCRC32", aws.ToString(c.ChecksumCRC32), "ChecksumCRC32C", aws.ToString(c.ChecksumCRC32C),
+			"ChecksumSHA1", aws.ToString(c.ChecksumSHA1), "ChecksumSHA256", aws.ToString(c.ChecksumSHA256))
+	}
+
+	// the parts are only reported for a multipart upload with checksums, at most 1000 of them in one response
+	if op := outGA.ObjectParts; op != nil {
+		slog.Info("object parts", "TotalPartsCount", op.TotalPartsCount, "IsTruncated", op.IsTruncated)
+		for _, p := range op.Parts {
+			slog.Info("object part", "PartNumber", p.PartNumber, "Size", p.Size, "ChecksumCRC32", aws.ToString(p.ChecksumCRC32),
+				"ChecksumCRC32C", aws.ToString(p.ChecksumCRC32C), "ChecksumSHA1", aws.ToString(p.ChecksumSHA1),
+				"ChecksumSHA256", aws.ToString(p.ChecksumSHA256))
+		}
+	}
+	return outGA, nil
+
+}
@@ -0,0 +1,63 @@
+package objectlock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// banner prints the Object Lock of an object on the output of the run - colored on a terminal only
+type banner struct {
+	w     io.Writer
+	color bool
+}
+
+// the ANSI colors of the lock banner
+const (
+	ansiRed    = "\x1b[1;31m"
+	ansiYellow = "\x1b[1;33m"
+	ansiReset  = "\x1b[0m"
+)
+
+func lockBanner(mode types.ObjectLockMode, retainUntil *time.Time, now time.Time) (banner string, color string) {
+
+	// the risk level of the retention in words - COMPLIANCE cannot be undone, GOVERNANCE can be bypassed
+
+	if mode == "" || retainUntil == nil {
+		return "", ""
+	}
+	until := retainUntil.UTC().Format(time.RFC3339)
+	if !retainUntil.After(now) {
+		return fmt.Sprintf("%s retention EXPIRED on %s - the object version can be deleted", mode, until), ""
+	}
+	switch mode {
+	case types.ObjectLockModeCompliance:
+		return fmt.Sprintf("⚠ COMPLIANCE - IMMUTABLE until %s, no user can delete the object version or shorten its retention, not even the root user", until), ansiRed
+	case types.ObjectLockModeGovernance:
+		return fmt.Sprintf("GOVERNANCE - protected until %s, but can be bypassed by privileged users with s3:BypassGovernanceRetention", until), ansiYellow
+	}
+	return "", ""
+
+}
+
+func (b banner) printLock(ctx context.Context, mode types.ObjectLockMode, retainUntil *time.Time) {
+
+	// the banner is a message like the log lines, so the quiet output omits it
+
+	if !slog.Default().Enabled(ctx, slog.LevelInfo) {
+		return
+	}
+	text, color := lockBanner(mode, retainUntil, time.Now())
+	if text == "" {
+		return
+	}
+	if b.color && color != "" {
+		text = color + text + ansiReset
+	}
+	fmt.Fprintln(b.w, text)
+
+}
@@ -0,0 +1,126 @@
+package objectlock
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func parseChecksumAlgorithm(algorithm string) (types.ChecksumAlgorithm, error) {
+
+	// map the checksum of the input argument to the S3 checksum algorithm - md5 keeps the Content-MD5 header
+
+	switch strings.ToLower(algorithm) {
+	case "md5":
+		return "", nil
+	case "sha256":
+		return types.ChecksumAlgorithmSha256, nil
+	case "crc32":
+		return types.ChecksumAlgorithmCrc32, nil
+	case "crc32c":
+		return types.ChecksumAlgorithmCrc32c, nil
+	}
+	return "", fmt.Errorf("invalid checksum %q [-checksum md5|sha256|crc32|crc32c]", algorithm)
+
+}
+
+func parseIntegrity(strategy string) (types.ChecksumAlgorithm, bool, error) {
+
+	// map the integrity strategy to the checksum algorithm and the omission of the Content-MD5 header
+	// the native crc32c of S3 also works for the multipart and SSE-KMS uploads, none relies on TLS only
+
+	switch strings.ToLower(strategy) {
+	case "md5":
+		return "", false, nil
+	case "crc32c":
+		return types.ChecksumAlgorithmCrc32c, true, nil
+	case "none":
+		return "", true, nil
+	}
+	return "", false, fmt.Errorf("invalid integrity strategy %q [-integrity md5|crc32c|none]", strategy)
+
+}
+
+func storedChecksum(c *types.Checksum, algorithm types.ChecksumAlgorithm) string {
+
+	// the checksum of the algorithm that S3 stored with the object, empty if it has none
+
+	if c == nil {
+		return ""
+	}
+	switch algorithm {
+	case types.ChecksumAlgorithmSha256:
+		return aws.ToString(c.ChecksumSHA256)
+	case types.ChecksumAlgorithmCrc32:
+		return aws.ToString(c.ChecksumCRC32)
+	case types.ChecksumAlgorithmCrc32c:
+		return aws.ToString(c.ChecksumCRC32C)
+	}
+	return ""
+
+}
+
+func headObjectChecksum(out *s3.HeadObjectOutput) *types.Checksum {
+
+	// HeadObject returns the checksums as headers instead of the checksum attribute
+
+	return &types.Checksum{
+		ChecksumCRC32:  out.ChecksumCRC32,
+		ChecksumCRC32C: out.ChecksumCRC32C,
+		ChecksumSHA1:   out.ChecksumSHA1,
+		ChecksumSHA256: out.ChecksumSHA256,
+	}
+
+}
+
+func verifyChecksum(key string, algorithm types.ChecksumAlgorithm, stored string, uploaded string, parts bool) error {
+
+	// the native checksum of S3 closes the integrity loop - a multipart upload has a checksum of the part checksums instead
+
+	switch {
+	case stored == "":
+		slog.Info("the endpoint returned no checksum of the object", "ChecksumAlgorithm", algorithm)
+	case parts || strings.Contains(stored, "-"):
+		slog.Info("the checksum of the object is a checksum of its parts, it is not compared", "checksum", stored)
+	case stored != uploaded:
+		return fmt.Errorf("FAIL - checksum verification of %s, the %s checksum %s differs from the uploaded %s", key, algorithm, stored, uploaded)
+	default:
+		slog.Info("PASS - checksum verification", "ChecksumAlgorithm", algorithm, "checksum", stored)
+	}
+	return nil
+
+}
+
+func getChecksum(r io.Reader, algorithm types.ChecksumAlgorithm) (string, error) {
+
+	// calculate the checksum of the content with the S3 checksum algorithm
+
+	var hasher hash.Hash
+	switch algorithm {
+	case types.ChecksumAlgorithmSha256:
+		hasher = sha256.New()
+	case types.ChecksumAlgorithmCrc32:
+		hasher = crc32.NewIEEE()
+	case types.ChecksumAlgorithmCrc32c:
+		hasher = crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	default:
+		return "", fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+	}
+	_, err := io.Copy(hasher, r)
+	if err != nil {
+		return "", err
+	}
+
+	// the checksum must be base64 encoded like the md5hash to be accepted by AWS
+	return base64.StdEncoding.EncodeToString(hasher.Sum(nil)), nil
+
+}
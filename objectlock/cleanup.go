@@ -0,0 +1,129 @@
+package objectlock
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func cleanup(ctx context.Context, client S3API, bucket string, objects []ObjectResult, deleteBucket bool, now time.Time) error {
+
+	// delete the uploaded object versions and the bucket of the run, so no billable test buckets are left
+	// a locked object version cannot be deleted - report it clearly and keep the bucket instead of failing
+
+	kept := 0
+	for _, or := range objects {
+		if or.VersionID == "" {
+			continue
+		}
+		if or.LegalHold == string(types.ObjectLockLegalHoldStatusOn) {
+			slog.Warn("cleanup - the object version keeps its legal hold, release it with set-legal-hold -status off",
+				"key", or.Key, "versionId", or.VersionID)
+			kept++
+			continue
+		}
+		if or.ObjectLockMode == string(types.ObjectLockModeCompliance) && or.RetainUntilDate != nil && or.RetainUntilDate.After(now) {
+			slog.Warn("cleanup - the COMPLIANCE object version cannot be deleted before its retention date",
+				"key", or.Key, "versionId", or.VersionID, "RetainUntilDate", or.RetainUntilDate.UTC().Format(time.RFC3339))
+			kept++
+			continue
+		}
+
+		// a GOVERNANCE object version needs the bypass - which requires the s3:BypassGovernanceRetention permission
+		bypass := or.ObjectLockMode == string(types.ObjectLockModeGovernance)
+		err := deleteObjectVersion(ctx, client, bucket, or.Key, or.VersionID, bypass)
+		if isAccessDenied(err) {
+			slog.Warn("cleanup - the delete of the object version is not authorized", "key", or.Key, "versionId", or.VersionID,
+				"ObjectLockMode", or.ObjectLockMode, "bypassGovernance", bypass)
+			kept++
+			continue
+		} else if err != nil {
+			return fmt.Errorf("cleanup of %s: %w", or.Key, err)
+		}
+		slog.Info("cleanup - DeleteObject - success!", "key", or.Key, "versionId", or.VersionID)
+	}
+
+	// a bucket with locked object versions cannot be deleted, and a bucket of a previous run is kept anyway
+	if kept > 0 {
+		slog.Warn("cleanup - the bucket is kept with locked object versions", "bucket", bucket, "locked", kept)
+		return nil
+	}
+	if !deleteBucket {
+		return nil
+	}
+	_, err := client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: &bucket})
+	if err != nil {
+		return fmt.Errorf("cleanup of bucket %s: %w", bucket, err)
+	}
+	slog.Info("cleanup - DeleteBucket - success!", "bucket", bucket)
+	return nil
+
+}
+
+// bucketDeleteTimeout limits the wait until a deleted bucket is gone for the recreation
+const bucketDeleteTimeout = 2 * time.Minute
+
+func recreateBucket(ctx context.Context, client S3API, bucket string, now time.Time) (deleted bool, err error) {
+
+	// delete an empty bucket, so it is created again with the current Object Lock configuration - e.g. without a default retention
+	// a bucket with object versions is refused, the locked ones cannot even be deleted before the end of their protection
+
+	out, err := client.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{Bucket: &bucket, MaxKeys: 100})
+	if isNoSuchBucket(err) {
+		slog.Info("the bucket does not exist, there is nothing to recreate", "bucket", bucket)
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("list object versions of %s: %w", bucket, err)
+	}
+
+	if len(out.Versions) == 0 && len(out.DeleteMarkers) == 0 {
+		_, err = client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: &bucket})
+		if err != nil {
+			return false, fmt.Errorf("delete bucket %s for the recreation: %w", bucket, err)
+		}
+		// HeadBucket may still find the deleted bucket for a while - the CreateBucket must not take it for an existing one
+		err = s3.NewBucketNotExistsWaiter(client).Wait(ctx, &s3.HeadBucketInput{Bucket: &bucket}, bucketDeleteTimeout)
+		if err != nil {
+			return false, fmt.Errorf("wait for the deletion of bucket %s: %w", bucket, err)
+		}
+		slog.Info("DeleteBucket - success! The empty bucket is recreated", "bucket", bucket)
+		return true, nil
+	}
+
+	// explain the refusal with the locked object versions of the first page
+	count := fmt.Sprint(len(out.Versions) + len(out.DeleteMarkers))
+	if out.IsTruncated {
+		count = "more than " + count
+	}
+	locked := 0
+	for _, v := range out.Versions {
+		key, versionID := aws.ToString(v.Key), aws.ToString(v.VersionId)
+		ret, err := getObjectRetention(ctx, client, bucket, key, versionID)
+		if err != nil && !isNoLockConfiguration(err) {
+			return false, fmt.Errorf("get retention of %s: %w", key, err)
+		}
+		status, err := getLegalHold(ctx, client, bucket, key, versionID)
+		if err != nil && !isNoLockConfiguration(err) {
+			return false, fmt.Errorf("get legal hold of %s: %w", key, err)
+		}
+		if ret != nil && ret.RetainUntilDate != nil && ret.RetainUntilDate.After(now) {
+			slog.Warn("the object version is locked", "key", key, "versionId", versionID, "Retention.Mode", ret.Mode,
+				"Retention.RetainUntilDate", ret.RetainUntilDate.UTC().Format(time.RFC3339))
+			locked++
+		} else if status == types.ObjectLockLegalHoldStatusOn {
+			slog.Warn("the object version has a legal hold", "key", key, "versionId", versionID)
+			locked++
+		}
+	}
+	if locked > 0 {
+		return false, fmt.Errorf("the bucket %s is not recreated, it has %s object versions and %d of them are locked - "+
+			"they cannot be deleted before their retention ends or their legal hold is released", bucket, count, locked)
+	}
+	return false, fmt.Errorf("the bucket %s is not recreated, it has %s object versions and delete markers - delete them before [-force-recreate]", bucket, count)
+
+}
@@ -0,0 +1,734 @@
+package objectlock
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Command is a subcommand of the CLI with its own flags
+type Command struct {
+	Summary string
+	Flags   func(cfg *Config, fs *flag.FlagSet)
+	Run     func(ctx context.Context, cfg *Config, res *Result) error
+}
+
+// CommandNames lists the subcommands in the order of the usage, demo is the default without a subcommand
+var CommandNames = []string{"demo", "create-bucket", "put-object", "get-status", "list", "set-legal-hold", "extend-retention", "compliance-check", "get-replication", "compare-config", "delete"}
+
+// Commands are the subcommands of the CLI by their names
+var Commands = map[string]Command{
+	"demo": {
+		Summary: "create a locked bucket, upload a locked object and verify it (default)",
+		Flags: func(cfg *Config, fs *flag.FlagSet) {
+			cfg.bucketFlags(fs)
+			cfg.retentionFlags(fs)
+			cfg.aclFlags(fs)
+			cfg.uploadFlags(fs)
+			cfg.verifyFlags(fs)
+			fs.BoolVar(&cfg.SkipCreate, "skip-create", false, "Use an existing bucket - skip the bucket creation and the default retention")
+			fs.BoolVar(&cfg.ForceRecreate, "force-recreate", false, "Delete an existing empty bucket and create it again with Object Lock - a bucket with objects is refused")
+			fs.BoolVar(&cfg.Delete, "delete", false, "Try to delete the object version after the verification to demonstrate the Object Lock protection")
+			fs.BoolVar(&cfg.BypassGovernance, "bypass-governance", false, "Delete the object version with a bypass of the GOVERNANCE retention")
+			fs.BoolVar(&cfg.Cleanup, "cleanup", false, "Delete the uploaded object versions and the created bucket at the end, unless they are locked")
+			fs.BoolVar(&cfg.PutOnly, "put-only", false, "Only upload and verify the locked objects in an existing lock-enabled bucket [-b BUCKET], like put-object")
+			fs.BoolVar(&cfg.HeadOnly, "head-only", false, "Only print the Object Lock status of an existing object [-b BUCKET -key KEY] - nothing is created or uploaded")
+		},
+		Run: runDemo,
+	},
+	"create-bucket": {
+		Summary: "create a bucket with Object Lock and its default retention",
+		Flags: func(cfg *Config, fs *flag.FlagSet) {
+			cfg.bucketFlags(fs)
+			cfg.retentionFlags(fs)
+			cfg.aclFlags(fs)
+			fs.BoolVar(&cfg.ForceRecreate, "force-recreate", false, "Delete an existing empty bucket and create it again with Object Lock - a bucket with objects is refused")
+		},
+		Run: runCreateBucket,
+	},
+	"put-object": {
+		Summary: "upload a locked object into an existing lock-enabled bucket",
+		Flags: func(cfg *Config, fs *flag.FlagSet) {
+			cfg.bucketFlags(fs)
+			cfg.uploadFlags(fs)
+			cfg.verifyFlags(fs)
+			cfg.aclFlags(fs)
+		},
+		Run: runPutObject,
+	},
+	"get-status": {
+		Summary: "print the Object Lock status of a bucket and optionally of an object",
+		Flags: func(cfg *Config, fs *flag.FlagSet) {
+			cfg.bucketFlags(fs)
+			cfg.objectFlags(fs)
+			cfg.verifyFlags(fs)
+		},
+		Run: runGetStatus,
+	},
+	"list": {
+		Summary: "list the objects of a bucket with their retention and legal hold",
+		Flags: func(cfg *Config, fs *flag.FlagSet) {
+			cfg.bucketFlags(fs)
+			fs.StringVar(&cfg.Prefix, "prefix", "", "List only the objects with keys of this prefix")
+			fs.StringVar(&cfg.FilterMode, "filter-mode", "", "List only the objects of the retention mode: governance, compliance or none")
+		},
+		Run: runList,
+	},
+	"set-legal-hold": {
+		Summary: "put or release the legal hold of an object",
+		Flags: func(cfg *Config, fs *flag.FlagSet) {
+			cfg.bucketFlags(fs)
+			cfg.objectFlags(fs)
+			fs.StringVar(&cfg.LegalHoldStatus, "status", "on", "The legal hold status: on or off")
+		},
+		Run: runSetLegalHold,
+	},
+	"extend-retention": {
+		Summary: "extend the retention date of an object",
+		Flags: func(cfg *Config, fs *flag.FlagSet) {
+			cfg.bucketFlags(fs)
+			cfg.objectFlags(fs)
+			fs.StringVar(&cfg.ExtendUntil, "extend-until", "", "The new retention date of the object in RFC3339 format, not earlier than the current one")
+		},
+		Run: runExtendRetention,
+	},
+	"compliance-check": {
+		Summary: "verify that a COMPLIANCE object can neither get a shorter or GOVERNANCE retention nor be deleted",
+		Flags: func(cfg *Config, fs *flag.FlagSet) {
+			cfg.bucketFlags(fs)
+			cfg.objectFlags(fs)
+		},
+		Run: runComplianceCheck,
+	},
+	"get-replication": {
+		Summary: "check whether the replication rules of a bucket preserve the Object Lock",
+		Flags: func(cfg *Config, fs *flag.FlagSet) {
+			cfg.bucketFlags(fs)
+		},
+		Run: runGetReplication,
+	},
+	"compare-config": {
+		Summary: "compare the Object Lock configuration of a bucket with the desired default retention",
+		Flags: func(cfg *Config, fs *flag.FlagSet) {
+			cfg.bucketFlags(fs)
+			cfg.retentionFlags(fs)
+		},
+		Run: runCompareConfig,
+	},
+	"delete": {
+		Summary: "try to delete an object version",
+		Flags: func(cfg *Config, fs *flag.FlagSet) {
+			cfg.bucketFlags(fs)
+			cfg.objectFlags(fs)
+			fs.BoolVar(&cfg.BypassGovernance, "bypass-governance", false, "Delete the object version with a bypass of the GOVERNANCE retention")
+		},
+		Run: runDelete,
+	},
+}
+
+// Run executes the complete Object Lock workflow with cfg: create the bucket, set its default retention,
+// upload and verify the locked objects. The Result describes the run even if it fails.
+func Run(ctx context.Context, cfg Config) (Result, error) {
+
+	res := Result{Bucket: cfg.Bucket}
+	err := runWorkflow(ctx, &cfg, &res)
+	return res, err
+
+}
+
+func runDemo(ctx context.Context, cfg *Config, res *Result) error {
+
+	// the command line drives the same workflow as the callers of Run
+	// into the Result of the command, which already holds the timestamp of the summary file
+
+	return runWorkflow(ctx, cfg, res)
+
+}
+
+func runWorkflow(ctx context.Context, cfg *Config, res *Result) error {
+
+	// the composite flow: create the bucket, set its default retention, upload and verify the locked objects
+
+	// a read-only audit of an existing object instead of the flow
+	if cfg.HeadOnly {
+		if cfg.Bucket == "" || cfg.Key == "" {
+			return errors.New("you must supply the bucket and the key of the object [-b BUCKET -key KEY] with [-head-only]")
+		}
+		return runGetStatus(ctx, cfg, res)
+	}
+	// the bucket and its policy are provisioned elsewhere, e.g. by Terraform - only the locked upload
+	if cfg.PutOnly {
+		return runPutObject(ctx, cfg, res)
+	}
+
+	// check the input arguments
+	if cfg.Filename == "" && cfg.URL == "" {
+		return errors.New("you must supply a filename [-f FILENAME] or a URL [-url URL]")
+	}
+	if cfg.Bucket == "" && cfg.SkipCreate {
+		return errors.New("you must supply the name of the existing bucket [-b BUCKET] with [-skip-create]")
+	}
+	if cfg.ForceRecreate && cfg.SkipCreate {
+		return errors.New("an existing bucket [-skip-create] cannot be recreated [-force-recreate]")
+	}
+	isDir, err := cfg.uploadTarget()
+	if err != nil {
+		return err
+	}
+	res.Key = cfg.Key
+	err = cfg.bucketName(res)
+	if err != nil {
+		return err
+	}
+	retention, err := cfg.defaultRetention()
+	if err != nil {
+		return err
+	}
+	_, bucketACL, err := parseACL(cfg.ACL)
+	if err != nil {
+		return err
+	}
+	ls, err := cfg.lockSettings()
+	if err != nil {
+		return err
+	}
+
+	// a preview of the run without any request to AWS
+	if cfg.DryRun {
+		return logDryRun(cfg, cfg.SkipCreate, retention, isDir, ls)
+	}
+
+	client, err := cfg.newClient(ctx, res)
+	if err != nil {
+		return err
+	}
+
+	// an existing bucket already has the Object Lock configured - unless it is recreated from scratch
+	recreated := false
+	if cfg.ForceRecreate {
+		recreated, err = recreateBucket(ctx, client, cfg.Bucket, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+	if !cfg.SkipCreate {
+		err = createBucket(ctx, client, cfg.Bucket, cfg.Region, bucketACL, retention, cfg.LockToken, recreated, res)
+		if err != nil {
+			return err
+		}
+	}
+	olc, err := checkBucket(ctx, client, cfg.Bucket, res)
+	if err != nil {
+		return err
+	}
+	err = upload(ctx, client, cfg, isDir, ls, olc, res)
+
+	// tear down the resources of the run - after a failed upload as well, a bucket of a previous run is kept
+	if cfg.Cleanup {
+		objects := res.Objects
+		if !isDir && len(objects) == 0 {
+			objects = []ObjectResult{res.ObjectResult}
+		}
+		err = errors.Join(err, cleanup(ctx, client, cfg.Bucket, objects, res.Created, time.Now()))
+	}
+	return err
+
+}
+
+func runCreateBucket(ctx context.Context, cfg *Config, res *Result) error {
+
+	// check the input arguments
+	err := cfg.bucketName(res)
+	if err != nil {
+		return err
+	}
+	retention, err := cfg.defaultRetention()
+	if err != nil {
+		return err
+	}
+	_, bucketACL, err := parseACL(cfg.ACL)
+	if err != nil {
+		return err
+	}
+
+	client, err := cfg.newClient(ctx, res)
+	if err != nil {
+		return err
+	}
+	recreated := false
+	if cfg.ForceRecreate {
+		recreated, err = recreateBucket(ctx, client, cfg.Bucket, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+	err = createBucket(ctx, client, cfg.Bucket, cfg.Region, bucketACL, retention, cfg.LockToken, recreated, res)
+	if err != nil {
+		return err
+	}
+	_, err = checkBucket(ctx, client, cfg.Bucket, res)
+	return err
+
+}
+
+func runPutObject(ctx context.Context, cfg *Config, res *Result) error {
+
+	// check the input arguments
+	if cfg.Bucket == "" || cfg.Filename == "" && cfg.URL == "" {
+		return errors.New("you must supply a bucket name [-b BUCKET] and a filename [-f FILENAME] or a URL [-url URL]")
+	}
+	isDir, err := cfg.uploadTarget()
+	if err != nil {
+		return err
+	}
+	res.Key = cfg.Key
+	ls, err := cfg.lockSettings()
+	if err != nil {
+		return err
+	}
+
+	// a preview of the run without any request to AWS
+	if cfg.DryRun {
+		return logDryRun(cfg, true, nil, isDir, ls)
+	}
+
+	client, err := cfg.newClient(ctx, res)
+	if err != nil {
+		return err
+	}
+
+	// the bucket must be lock-enabled before the locked upload
+	olc, err := checkBucket(ctx, client, cfg.Bucket, res)
+	if err != nil {
+		return err
+	}
+	return upload(ctx, client, cfg, isDir, ls, olc, res)
+
+}
+
+func runGetStatus(ctx context.Context, cfg *Config, res *Result) error {
+
+	// check the input arguments
+	if cfg.Bucket == "" {
+		return errors.New("you must supply a bucket name [-b BUCKET]")
+	}
+	res.Key = cfg.Key
+	verifyAPI, err := parseVerifyAPI(cfg.VerifyAPI)
+	if err != nil {
+		return err
+	}
+
+	client, err := cfg.newClient(ctx, res)
+	if err != nil {
+		return err
+	}
+	return getStatus(ctx, client, cfg, verifyAPI, res)
+
+}
+
+func getStatus(ctx context.Context, client S3API, cfg *Config, verifyAPI string, res *Result) error {
+
+	// the read-only audit of -head-only needs no bucket permissions, only HeadObject, GetObjectRetention and GetObjectLegalHold
+	if !cfg.HeadOnly {
+		_, _, err := describeBucket(ctx, client, cfg.Bucket, res)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the status of the object is optional - an object without a lock has neither retention nor legal hold
+	if cfg.Key == "" {
+		return nil
+	}
+	_, err := describeObject(ctx, client, cfg.Bucket, cfg.Key, cfg.VersionID, &res.ObjectResult, false, false, "", cfg.banner())
+	if err == nil && verifyAPI == verifyAttributes {
+		_, err = describeAttributes(ctx, client, cfg.Bucket, cfg.Key, res.VersionID)
+	}
+	return err
+
+}
+
+func runList(ctx context.Context, cfg *Config, res *Result) error {
+
+	// check the input arguments
+	if cfg.Bucket == "" {
+		return errors.New("you must supply a bucket name [-b BUCKET]")
+	}
+	filterMode, err := parseFilterMode(cfg.FilterMode)
+	if err != nil {
+		return err
+	}
+
+	client, err := cfg.newClient(ctx, res)
+	if err != nil {
+		return err
+	}
+	res.Objects, err = listLockedObjects(ctx, client, cfg.Bucket, cfg.Prefix, filterMode)
+	if err != nil {
+		return err
+	}
+	slog.Info("ListObjectsV2 - success!", "bucket", cfg.Bucket, "prefix", cfg.Prefix, "filterMode", filterMode, "objects", len(res.Objects))
+	err = printObjectTable(cfg.output(), res.Objects)
+	if err != nil {
+		return err
+	}
+	printObjectSummary(cfg.output(), res.Objects)
+	return nil
+
+}
+
+func runSetLegalHold(ctx context.Context, cfg *Config, res *Result) error {
+
+	// check the input arguments
+	if cfg.Bucket == "" || cfg.Key == "" {
+		return errors.New("you must supply a bucket name [-b BUCKET] and a key [-key KEY]")
+	}
+	res.Key = cfg.Key
+	var status types.ObjectLockLegalHoldStatus
+	switch strings.ToLower(cfg.LegalHoldStatus) {
+	case "on":
+		status = types.ObjectLockLegalHoldStatusOn
+	case "off":
+		status = types.ObjectLockLegalHoldStatusOff
+	default:
+		return fmt.Errorf("invalid legal hold status %q, expected on or off [-status on|off]", cfg.LegalHoldStatus)
+	}
+
+	client, err := cfg.newClient(ctx, res)
+	if err != nil {
+		return err
+	}
+	err = setLegalHold(ctx, client, cfg.Bucket, cfg.Key, cfg.VersionID, status)
+	if err != nil {
+		return fmt.Errorf("put legal hold of %s: %w", cfg.Key, err)
+	}
+	slog.Info("PutObjectLegalHold - success!", "key", cfg.Key, "Status", status)
+
+	// read the legal hold back
+	current, err := getLegalHold(ctx, client, cfg.Bucket, cfg.Key, cfg.VersionID)
+	if err != nil {
+		return fmt.Errorf("get legal hold of %s: %w", cfg.Key, err)
+	}
+	res.VersionID = cfg.VersionID
+	res.LegalHold = string(current)
+	slog.Info("object legal hold", "ObjectLockLegalHoldStatus", current)
+	return nil
+
+}
+
+func runExtendRetention(ctx context.Context, cfg *Config, res *Result) error {
+
+	// check the input arguments
+	if cfg.Bucket == "" || cfg.Key == "" || cfg.ExtendUntil == "" {
+		return errors.New("you must supply a bucket name [-b BUCKET], a key [-key KEY] and a retention date [-extend-until 2030-01-31T00:00:00Z]")
+	}
+	res.Key = cfg.Key
+	until, err := time.Parse(time.RFC3339, cfg.ExtendUntil)
+	if err != nil {
+		return fmt.Errorf("invalid retention date %q [-extend-until 2030-01-31T00:00:00Z]", cfg.ExtendUntil)
+	}
+	until = until.UTC()
+
+	client, err := cfg.newClient(ctx, res)
+	if err != nil {
+		return err
+	}
+	old, err := extendRetention(ctx, client, cfg.Bucket, cfg.Key, cfg.VersionID, until)
+	var shortened *RetentionShortenedError
+	if errors.As(err, &shortened) {
+		return fmt.Errorf("extend retention of %s, choose a date after %s [-extend-until]: %w", cfg.Key,
+			shortened.Current.UTC().Format(time.RFC3339), err)
+	} else if err != nil {
+		return fmt.Errorf("extend retention of %s: %w", cfg.Key, err)
+	}
+	res.VersionID = cfg.VersionID
+	res.ObjectLockMode = string(old.Mode)
+	res.RetainUntilDate = &until
+	slog.Info("PutObjectRetention - success!", "key", cfg.Key, "Retention.Mode", old.Mode,
+		"RetainUntilDate.old", old.RetainUntilDate.Format(time.RFC3339), "RetainUntilDate.new", until.Format(time.RFC3339))
+	return nil
+
+}
+
+func runComplianceCheck(ctx context.Context, cfg *Config, res *Result) error {
+
+	// check the input arguments
+	if cfg.Bucket == "" || cfg.Key == "" {
+		return errors.New("you must supply a bucket name [-b BUCKET] and a key [-key KEY]")
+	}
+	res.Key = cfg.Key
+
+	client, err := cfg.newClient(ctx, res)
+	if err != nil {
+		return err
+	}
+
+	// without a version id the check would hit the latest version only - so resolve it for the report
+	outHO, err := headObject(ctx, client, cfg.Bucket, cfg.Key, cfg.VersionID, "")
+	if err != nil {
+		return fmt.Errorf("head object %s: %w", cfg.Key, err)
+	}
+	res.VersionID = aws.ToString(outHO.VersionId)
+	res.ObjectLockMode = string(outHO.ObjectLockMode)
+	res.RetainUntilDate = outHO.ObjectLockRetainUntilDate
+	return checkComplianceLock(ctx, client, cfg.Bucket, cfg.Key, res.VersionID, time.Now())
+
+}
+
+func runGetReplication(ctx context.Context, cfg *Config, res *Result) error {
+
+	// check the input arguments
+	if cfg.Bucket == "" {
+		return errors.New("you must supply a bucket name [-b BUCKET]")
+	}
+
+	client, err := cfg.newClient(ctx, res)
+	if err != nil {
+		return err
+	}
+	res.Replication, err = checkReplication(ctx, client, cfg.Bucket)
+	return err
+
+}
+
+func runCompareConfig(ctx context.Context, cfg *Config, res *Result) error {
+
+	// check the input arguments
+	if cfg.Bucket == "" {
+		return errors.New("you must supply a bucket name [-b BUCKET]")
+	}
+	retention, err := cfg.defaultRetention()
+	if err != nil {
+		return err
+	}
+
+	client, err := cfg.newClient(ctx, res)
+	if err != nil {
+		return err
+	}
+	olc, err := getObjectLockConfiguration(ctx, client, cfg.Bucket)
+	if isNoObjectLockConfiguration(err) {
+		olc = &types.ObjectLockConfiguration{}
+	} else if err != nil {
+		return fmt.Errorf("get object lock configuration of %s: %w", cfg.Bucket, err)
+	}
+
+	// a drift fails the run, so a compliance pipeline detects it by the exit code
+	res.Drift = diffLockConfiguration(olc, retention)
+	if len(res.Drift) == 0 {
+		slog.Info("PASS - the Object Lock configuration matches the desired one", "bucket", cfg.Bucket)
+		return nil
+	}
+	err = printDifferences(cfg.output(), res.Drift)
+	if err != nil {
+		return err
+	}
+	settings := make([]string, len(res.Drift))
+	for i, d := range res.Drift {
+		settings[i] = d.Setting
+	}
+	return fmt.Errorf("the Object Lock configuration of bucket %s has drifted in %s", cfg.Bucket, strings.Join(settings, ", "))
+
+}
+
+func runDelete(ctx context.Context, cfg *Config, res *Result) error {
+
+	// check the input arguments
+	if cfg.Bucket == "" || cfg.Key == "" {
+		return errors.New("you must supply a bucket name [-b BUCKET] and a key [-key KEY]")
+	}
+	res.Key = cfg.Key
+
+	client, err := cfg.newClient(ctx, res)
+	if err != nil {
+		return err
+	}
+
+	// without a version id S3 would only add a delete marker - so delete the latest version explicitly
+	outHO, err := headObject(ctx, client, cfg.Bucket, cfg.Key, cfg.VersionID, "")
+	if err != nil {
+		return fmt.Errorf("head object %s: %w", cfg.Key, err)
+	}
+	res.VersionID = aws.ToString(outHO.VersionId)
+	res.ObjectLockMode = string(outHO.ObjectLockMode)
+	res.RetainUntilDate = outHO.ObjectLockRetainUntilDate
+	return tryDeleteObject(ctx, client, cfg.Bucket, cfg.Key, res.VersionID, outHO.ObjectLockMode, outHO.ObjectLockRetainUntilDate, cfg.BypassGovernance)
+
+}
+
+func createBucket(ctx context.Context, client S3API, bucket string, region string, acl types.BucketCannedACL,
+	retention *types.DefaultRetention, token string, recreated bool, res *Result) error {
+
+	// create the bucket with Object Lock
+	created, err := createLockedBucket(ctx, client, bucket, region, acl, recreated)
+	if isObjectLockUnsupported(err) {
+		return fmt.Errorf("create bucket %s: %w: %w", bucket, ErrObjectLockUnsupported, err)
+	} else if err != nil {
+		return fmt.Errorf("create bucket %s: %w", bucket, err)
+	}
+	res.Created = created
+	if created {
+		slog.Info("bucket created!!!", "bucket", bucket)
+	} else {
+		slog.Info("bucket already exists, continuing", "bucket", bucket)
+	}
+
+	// a repeated run on an existing bucket skips the default retention, if it matches already
+	// us-east-1 answers the CreateBucket of an owned bucket with 200 OK, so even a created bucket may be an old one
+	olc, err := getObjectLockConfiguration(ctx, client, bucket)
+	if err == nil && hasDefaultRetention(olc, retention) {
+		slog.Info("retention already configured", "bucket", bucket)
+		return nil
+	}
+
+	// put the default retention period on the bucket - here: *** the chosen mode, 2 days if nothing else is set ***
+	err = setDefaultRetention(ctx, client, bucket, retention, token)
+	if isLockTokenError(err) {
+		return fmt.Errorf("put default retention of %s, enabling Object Lock on an existing bucket requires a valid token [-lock-token TOKEN]: %w", bucket, err)
+	} else if isObjectLockUnsupported(err) {
+		return fmt.Errorf("put default retention of %s: %w: %w", bucket, ErrObjectLockUnsupported, err)
+	} else if err != nil {
+		return fmt.Errorf("put default retention of %s: %w", bucket, err)
+	}
+	if retention == nil {
+		slog.Info("PutObjectLockConfiguration - success! No default retention")
+		return nil
+	}
+	slog.Info("PutObjectLockConfiguration - success!", "mode", retention.Mode, "days", retention.Days, "years", retention.Years)
+	return nil
+
+}
+
+func describeBucket(ctx context.Context, client S3API, bucket string, res *Result) (types.BucketVersioningStatus, *types.ObjectLockConfiguration, error) {
+
+	// log the versioning and the Object Lock settings of the bucket
+
+	versioning, err := getBucketVersioning(ctx, client, bucket)
+	if err != nil {
+		return "", nil, fmt.Errorf("get versioning of %s: %w", bucket, err)
+	}
+	slog.Info("bucket versioning", "bucket", bucket, "Status", versioning)
+
+	// request the Object Lock settings - a bucket created without Object Lock has none at all
+	olc, err := getObjectLockConfiguration(ctx, client, bucket)
+	if isNoObjectLockConfiguration(err) {
+		slog.Info("bucket has no Object Lock configuration - it was created without Object Lock", "bucket", bucket)
+		return versioning, &types.ObjectLockConfiguration{}, nil
+	} else if err != nil {
+		return versioning, nil, fmt.Errorf("get object lock configuration of %s: %w", bucket, err)
+	}
+	// log the settings - a rule may come without a default retention
+	if olc.Rule != nil && olc.Rule.DefaultRetention != nil {
+		res.DefaultRetentionMode = string(olc.Rule.DefaultRetention.Mode)
+		res.DefaultRetentionDays = olc.Rule.DefaultRetention.Days
+		res.DefaultRetentionYears = olc.Rule.DefaultRetention.Years
+		slog.Info("Object Lock configuration", "bucket", bucket, "ObjectLockEnabled", olc.ObjectLockEnabled,
+			"DefaultRetention.Mode", olc.Rule.DefaultRetention.Mode,
+			"DefaultRetention.Days", olc.Rule.DefaultRetention.Days,
+			"DefaultRetention.Years", olc.Rule.DefaultRetention.Years)
+	} else {
+		slog.Info("Object Lock configuration, but there is NO ObjectLockConfiguration.Rule <nil>",
+			"bucket", bucket, "ObjectLockEnabled", olc.ObjectLockEnabled)
+	}
+	return versioning, olc, nil
+
+}
+
+func checkBucket(ctx context.Context, client S3API, bucket string, res *Result) (*types.ObjectLockConfiguration, error) {
+
+	// confirm the versioning and the Object Lock of the bucket - without them the Object Lock semantics break
+	// fail before the upload, S3 would reject the Object Lock headers of the PutObject with a confusing error
+
+	versioning, olc, err := describeBucket(ctx, client, bucket, res)
+	if err != nil {
+		return nil, err
+	}
+	if olc.ObjectLockEnabled != types.ObjectLockEnabledEnabled {
+		return nil, fmt.Errorf("object lock is NOT enabled for bucket %s - use a lock-enabled bucket or enable it with create-bucket [-lock-token TOKEN]", bucket)
+	}
+	if versioning != types.BucketVersioningStatusEnabled {
+		return nil, fmt.Errorf("versioning is NOT enabled for bucket %s, Object Lock will not work", bucket)
+	}
+	return olc, nil
+
+}
+
+func upload(ctx context.Context, client S3API, cfg *Config, isDir bool, ls lockSettings, olc *types.ObjectLockConfiguration, res *Result) error {
+
+	// the object should be protected at least as long as the policy of the bucket demands
+	if olc.Rule != nil {
+		err := checkRetentionAgainstDefault(ls.RetainUntil, olc.Rule.DefaultRetention, time.Now().UTC())
+		if err != nil && cfg.StrictRetention {
+			return err
+		} else if err != nil {
+			slog.Warn(err.Error())
+		}
+	}
+
+	// upload the file - or each file of the directory - as a locked object
+	var err error
+	if isDir {
+		res.Objects, err = lockDirectory(ctx, client, cfg.Bucket, cfg.Filename, ls, cfg.Concurrency)
+		return err
+	}
+	filename := cfg.Filename
+	if filename == stdinFilename {
+		filename, err = bufferStdin()
+		if err != nil {
+			return err
+		}
+		defer os.Remove(filename)
+	}
+	// a remote artifact keeps the content type of the server, unless it is set explicitly
+	if cfg.URL != "" {
+		var contentType string
+		filename, contentType, err = bufferURL(ctx, cfg.URL)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(filename)
+		if ls.ContentType == "" {
+			ls.ContentType = contentType
+		}
+	}
+	if cfg.Versions == 1 {
+		res.ObjectResult, err = lockFile(ctx, client, cfg.Bucket, filename, cfg.Key, ls)
+		return err
+	}
+
+	// the versioned bucket keeps each upload of the key as a new version with its own Object Lock
+	for i := 1; i <= cfg.Versions; i++ {
+		slog.Info("upload of the object version", "key", cfg.Key, "version", i, "versions", cfg.Versions)
+		res.ObjectResult, err = lockFile(ctx, client, cfg.Bucket, filename, cfg.Key, ls)
+		if err != nil {
+			res.ObjectResult.Error = err.Error()
+		}
+		res.Objects = append(res.Objects, res.ObjectResult)
+		if err != nil {
+			return err
+		}
+	}
+	versions, err := listObjectVersions(ctx, client, cfg.Bucket, cfg.Key)
+	if err != nil {
+		return err
+	}
+	slog.Info("ListObjectVersions - success!", "key", cfg.Key, "versions", len(versions))
+	for _, or := range versions {
+		until := "-"
+		if or.RetainUntilDate != nil {
+			until = or.RetainUntilDate.UTC().Format(time.RFC3339)
+		}
+		slog.Info("object version", "key", or.Key, "versionId", or.VersionID, "Retention.Mode", or.ObjectLockMode,
+			"Retention.RetainUntilDate", until, "LegalHold", or.LegalHold)
+	}
+	return nil
+
+}
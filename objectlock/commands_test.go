@@ -0,0 +1,63 @@
+package objectlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestCreateBucketDefaultRetention(t *testing.T) {
+
+	// the default retention of a bucket is compared before the put, even if CreateBucket reported success
+
+	retention := &types.DefaultRetention{Mode: types.ObjectLockRetentionModeGovernance, Days: 2}
+	tests := []struct {
+		name    string
+		current *types.DefaultRetention
+		wantPut int
+	}{
+		{name: "same retention", current: &types.DefaultRetention{Mode: types.ObjectLockRetentionModeGovernance, Days: 2}, wantPut: 0},
+		{name: "other retention", current: &types.DefaultRetention{Mode: types.ObjectLockRetentionModeCompliance, Days: 2}, wantPut: 1},
+		{name: "no retention", current: nil, wantPut: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeS3{lockConfiguration: types.ObjectLockConfiguration{
+				ObjectLockEnabled: types.ObjectLockEnabledEnabled,
+				Rule:              &types.ObjectLockRule{DefaultRetention: tt.current},
+			}}
+			err := createBucket(context.Background(), client, "test-wormbucket", "us-east-1", "", retention, "", false, &Result{})
+			if err != nil {
+				t.Fatalf("createBucket() error = %v", err)
+			}
+			if len(client.putLockArgs) != tt.wantPut {
+				t.Errorf("createBucket() made %d PutObjectLockConfiguration calls, want %d", len(client.putLockArgs), tt.wantPut)
+			}
+		})
+	}
+
+}
+
+func TestGetStatusHeadOnly(t *testing.T) {
+
+	// an audit role without bucket permissions still sees the object - the fake panics on any bucket call
+
+	until := time.Date(2030, 1, 31, 12, 0, 0, 0, time.UTC)
+	client := &fakeS3{retention: types.ObjectLockRetention{Mode: types.ObjectLockRetentionModeCompliance, RetainUntilDate: aws.Time(until)}}
+	cfg := &Config{Bucket: "test-wormbucket", Key: "iris.csv", HeadOnly: true}
+	res := &Result{}
+	err := getStatus(context.Background(), client, cfg, verifyHead, res)
+	if err != nil {
+		t.Fatalf("getStatus() error = %v", err)
+	}
+	if res.ObjectLockMode != string(types.ObjectLockModeCompliance) || !res.RetainUntilDate.Equal(until) {
+		t.Errorf("getStatus() = %s until %v, want COMPLIANCE until %s", res.ObjectLockMode, res.RetainUntilDate, until)
+	}
+	if len(client.headObjectArgs) != 1 {
+		t.Errorf("getStatus() made %d HeadObject calls, want 1", len(client.headObjectArgs))
+	}
+
+}
@@ -0,0 +1,140 @@
+package objectlock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+func checkComplianceLock(ctx context.Context, client S3API, bucket string, key string, versionID string, now time.Time) error {
+
+	// a conformance test of the WORM guarantee: a COMPLIANCE object version within its retention period
+	// must reject a shorter retention, a downgrade to GOVERNANCE and a delete - even with the bypass of the GOVERNANCE retention
+	// only a GOVERNANCE retention may be upgraded to COMPLIANCE, a COMPLIANCE retention may only be extended
+
+	ret, err := getObjectRetention(ctx, client, bucket, key, versionID)
+	if err != nil {
+		return fmt.Errorf("get retention of %s: %w", key, err)
+	}
+	if ret.Mode != types.ObjectLockRetentionModeCompliance || ret.RetainUntilDate == nil || !ret.RetainUntilDate.After(now) {
+		return fmt.Errorf("the object %s is not a COMPLIANCE object within its retention period, mode %q", key, ret.Mode)
+	}
+	slog.Info("object retention", "Retention.Mode", ret.Mode, "Retention.RetainUntilDate", ret.RetainUntilDate.UTC().Format(time.RFC3339))
+
+	// (a) shorten the retention - halfway between now and the current retention date
+	earlier := now.Add(ret.RetainUntilDate.Sub(now) / 2).UTC()
+	err = putObjectRetention(ctx, client, bucket, key, versionID, ret.Mode, earlier)
+	if err == nil {
+		return fmt.Errorf("the retention of the COMPLIANCE object %s was shortened to %s - the WORM guarantee is broken", key, earlier.Format(time.RFC3339))
+	} else if !isLockRejection(err) {
+		return fmt.Errorf("shorten retention of %s: %w", key, err)
+	}
+	slog.Info("PutObjectRetention with an earlier date - blocked by the Object Lock", "key", key,
+		"RetainUntilDate", earlier.Format(time.RFC3339), "error", apiError{err})
+
+	// (b) downgrade the mode to GOVERNANCE with the same date - a GOVERNANCE object could be deleted with the bypass
+	err = putObjectRetention(ctx, client, bucket, key, versionID, types.ObjectLockRetentionModeGovernance, *ret.RetainUntilDate)
+	if err == nil {
+		return fmt.Errorf("the retention of the COMPLIANCE object %s was downgraded to GOVERNANCE - the WORM guarantee is broken", key)
+	} else if !isLockRejection(err) {
+		return fmt.Errorf("downgrade retention of %s: %w", key, err)
+	}
+	slog.Info("PutObjectRetention with the mode GOVERNANCE - blocked by the Object Lock", "key", key, "error", apiError{err})
+
+	// (c) delete the object version
+	err = deleteObjectVersion(ctx, client, bucket, key, versionID, true)
+	if err == nil {
+		return fmt.Errorf("the COMPLIANCE object %s was deleted before its retention date - the WORM guarantee is broken", key)
+	} else if !isLockRejection(err) {
+		return fmt.Errorf("delete object %s: %w", key, err)
+	}
+	slog.Info("DeleteObject with bypass of the GOVERNANCE retention - blocked by the Object Lock", "key", key, "error", apiError{err})
+
+	slog.Info("PASS - COMPLIANCE retention can neither be shortened nor downgraded and the object cannot be deleted", "key", key)
+	return nil
+
+}
+
+// maxExpiryPoll is the longest pause between the retention requests while waiting for the end of a retention
+const maxExpiryPoll = 5 * time.Second
+
+func waitForExpiry(ctx context.Context, client S3API, bucket string, key string, versionID string) error {
+
+	// the full lifecycle of a short test retention: wait until the retention date has passed, then the delete must succeed
+	// the delete is retried while the endpoint still blocks it, the clock of the endpoint may be behind the local one
+
+	ret, err := getObjectRetention(ctx, client, bucket, key, versionID)
+	if err != nil {
+		return fmt.Errorf("get retention of %s: %w", key, err)
+	}
+	if ret.RetainUntilDate == nil {
+		return fmt.Errorf("the object %s has no retention date to wait for", key)
+	}
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(*ret.RetainUntilDate) {
+		return fmt.Errorf("the retention of %s ends at %s after the timeout of the run - use a shorter retention [-retain 10s] or a longer timeout [-timeout]",
+			key, ret.RetainUntilDate.UTC().Format(time.RFC3339))
+	}
+	slog.Info("waiting for the end of the retention", "key", key, "RetainUntilDate", ret.RetainUntilDate.UTC().Format(time.RFC3339),
+		"retention", formatRemaining(*ret.RetainUntilDate, time.Now()))
+
+	for {
+		// the retention may have been extended in the meantime
+		ret, err = getObjectRetention(ctx, client, bucket, key, versionID)
+		if err != nil {
+			return fmt.Errorf("get retention of %s: %w", key, err)
+		}
+		if ret.RetainUntilDate != nil && ret.RetainUntilDate.After(time.Now()) {
+			err = sleep(ctx, min(time.Until(*ret.RetainUntilDate), maxExpiryPoll))
+			if err != nil {
+				return fmt.Errorf("wait for the end of the retention of %s: %w", key, err)
+			}
+			continue
+		}
+
+		err = deleteObjectVersion(ctx, client, bucket, key, versionID, false)
+		if err == nil {
+			slog.Info("PASS - the object version is deleted after the end of its retention", "key", key, "versionId", versionID)
+			return nil
+		} else if !isLockRejection(err) {
+			return fmt.Errorf("delete object %s: %w", key, err)
+		}
+		slog.Info("DeleteObject after the end of the retention - still blocked, the clock of the endpoint may be behind", "key", key)
+		err = sleep(ctx, time.Second)
+		if err != nil {
+			return fmt.Errorf("wait for the end of the retention of %s: %w", key, err)
+		}
+	}
+
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+
+	// a pause that ends early with the cancellation or the timeout of the run
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+
+}
+
+func isLockRejection(err error) bool {
+
+	// AWS S3 rejects a change of a locked object with AccessDenied, S3 compatible storages like MinIO with InvalidRequest
+
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.ErrorCode() == "AccessDenied" || apiErr.ErrorCode() == "InvalidRequest"
+
+}
@@ -0,0 +1,58 @@
+package objectlock
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ConfigDifference is a setting of the Object Lock configuration that differs from the desired one
+type ConfigDifference struct {
+	Setting string `json:"setting"`
+	Desired string `json:"desired"`
+	Actual  string `json:"actual"`
+}
+
+func diffLockConfiguration(olc *types.ObjectLockConfiguration, retention *types.DefaultRetention) []ConfigDifference {
+
+	// compare the Object Lock of the bucket setting by setting - nil means no default retention
+	// a missing default retention is shown as a dash
+
+	desired := []string{string(types.ObjectLockEnabledEnabled), "-", "-", "-"}
+	if retention != nil {
+		desired[1], desired[2], desired[3] = string(retention.Mode), strconv.Itoa(int(retention.Days)), strconv.Itoa(int(retention.Years))
+	}
+	actual := []string{"-", "-", "-", "-"}
+	if olc != nil && olc.ObjectLockEnabled != "" {
+		actual[0] = string(olc.ObjectLockEnabled)
+	}
+	if olc != nil && olc.Rule != nil && olc.Rule.DefaultRetention != nil {
+		current := olc.Rule.DefaultRetention
+		actual[1], actual[2], actual[3] = string(current.Mode), strconv.Itoa(int(current.Days)), strconv.Itoa(int(current.Years))
+	}
+
+	var diffs []ConfigDifference
+	for i, setting := range []string{"ObjectLockEnabled", "DefaultRetention.Mode", "DefaultRetention.Days", "DefaultRetention.Years"} {
+		if desired[i] != actual[i] {
+			diffs = append(diffs, ConfigDifference{Setting: setting, Desired: desired[i], Actual: actual[i]})
+		}
+	}
+	return diffs
+
+}
+
+func printDifferences(w io.Writer, diffs []ConfigDifference) error {
+
+	// print the drift as aligned columns
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tDESIRED\tACTUAL")
+	for _, d := range diffs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", d.Setting, d.Desired, d.Actual)
+	}
+	return tw.Flush()
+
+}
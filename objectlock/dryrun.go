@@ -0,0 +1,144 @@
+package objectlock
+
+import (
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func logDryRun(cfg *Config, skipCreate bool, retention *types.DefaultRetention, isDir bool, ls lockSettings) error {
+
+	// log each intended API call with its key parameters - nothing is sent to AWS
+	// COMPLIANCE objects cannot be deleted, so the preview is a safety net for the irreversible uploads
+
+	bucket := cfg.Bucket
+
+	// the bucket is only deleted for the recreation if it has no object versions at all
+	if cfg.ForceRecreate {
+		slog.Info("dry-run: ListObjectVersions", "bucket", bucket, "MaxKeys", 100)
+		slog.Info("dry-run: DeleteBucket, only if the bucket is empty", "bucket", bucket)
+	}
+	if !skipCreate {
+		slog.Info("dry-run: HeadBucket", "bucket", bucket)
+		slog.Info("dry-run: CreateBucket", "bucket", bucket, "ObjectLockEnabledForBucket", true)
+		if retention != nil {
+			slog.Info("dry-run: PutObjectLockConfiguration", "bucket", bucket,
+				"DefaultRetention.Mode", retention.Mode, "DefaultRetention.Days", retention.Days, "DefaultRetention.Years", retention.Years)
+		} else {
+			slog.Info("dry-run: PutObjectLockConfiguration", "bucket", bucket, "ObjectLockEnabled", types.ObjectLockEnabledEnabled, "Rule", "<nil>")
+		}
+	}
+	slog.Info("dry-run: GetBucketVersioning", "bucket", bucket)
+	slog.Info("dry-run: GetObjectLockConfiguration", "bucket", bucket)
+
+	// the uploaded keys in the order of the cleanup at the end of the run
+	var keys []string
+	logObject := func(path string, key string) error {
+		logDryRunObject(bucket, path, key, ls)
+		keys = append(keys, key)
+		return nil
+	}
+	if isDir {
+		err := walkFiles(cfg.Filename, ls.KeyPrefix, logObject)
+		if err != nil {
+			return err
+		}
+	} else {
+		// a remote artifact is downloaded into a temporary file first, its size is only known then
+		filename := cfg.Filename
+		if cfg.URL != "" {
+			slog.Info("dry-run: HTTP GET", "url", cfg.URL)
+			filename = ""
+		}
+		// each version of a single file is a PutObject of its own, the versions are listed afterwards
+		for i := 0; i < cfg.Versions; i++ {
+			logObject(filename, cfg.Key)
+		}
+		if cfg.Versions > 1 {
+			slog.Info("dry-run: ListObjectVersions", "bucket", bucket, "Prefix", cfg.Key)
+		}
+	}
+	if cfg.Cleanup {
+		logDryRunCleanup(bucket, skipCreate, keys, ls)
+	}
+	return nil
+
+}
+
+func logDryRunObject(bucket string, path string, key string, ls lockSettings) {
+
+	// the API calls of lockFile for one object, with the same branches on the lock settings
+	// the size of stdin and of a download is unknown, it is uploaded in parts if it reaches the threshold
+
+	size := int64(-1)
+	if fi, err := os.Stat(path); err == nil && path != stdinFilename {
+		size = fi.Size()
+	}
+	retainUntil := ls.RetainUntil.Format(time.RFC3339)
+	switch {
+	case size < 0 && ls.MultipartThreshold > 0:
+		slog.Info("dry-run: PutObject, or a multipart upload if the content has the threshold size", "bucket", bucket, "key", key,
+			"file", path, "MultipartThreshold", ls.MultipartThreshold, "ObjectLockMode", ls.Mode, "ObjectLockRetainUntilDate", retainUntil)
+	case size >= 0 && isMultipart(ls.MultipartThreshold, size):
+		partSize := ls.PartSize
+		if partSize <= 0 {
+			partSize = manager.DefaultUploadPartSize
+		}
+		slog.Info("dry-run: CreateMultipartUpload", "bucket", bucket, "key", key, "file", path, "size", size,
+			"ObjectLockMode", ls.Mode, "ObjectLockRetainUntilDate", retainUntil, "ChecksumAlgorithm", partChecksumAlgorithm(ls.ChecksumAlgorithm))
+		slog.Info("dry-run: UploadPart", "bucket", bucket, "key", key, "parts", (size+partSize-1)/partSize, "PartSize", partSize)
+		slog.Info("dry-run: CompleteMultipartUpload", "bucket", bucket, "key", key)
+	default:
+		slog.Info("dry-run: PutObject", "bucket", bucket, "key", key, "file", path,
+			"ObjectLockMode", ls.Mode, "ObjectLockRetainUntilDate", retainUntil)
+	}
+	if ls.LegalHold {
+		slog.Info("dry-run: PutObjectLegalHold", "bucket", bucket, "key", key, "Status", types.ObjectLockLegalHoldStatusOn)
+	}
+
+	// the verification of the Object Lock and of the integrity - the object attributes replace HeadObject
+	if ls.VerifyAPI == verifyAttributes {
+		slog.Info("dry-run: GetObjectAttributes", "bucket", bucket, "key", key)
+	} else {
+		slog.Info("dry-run: HeadObject", "bucket", bucket, "key", key, "ChecksumMode", ls.checksumMode(ls.ChecksumAlgorithm != ""))
+	}
+	slog.Info("dry-run: GetObjectRetention", "bucket", bucket, "key", key)
+	slog.Info("dry-run: GetObjectLegalHold", "bucket", bucket, "key", key)
+	if ls.VerifyDownload {
+		slog.Info("dry-run: GetObject", "bucket", bucket, "key", key)
+	}
+
+	if ls.Delete || ls.BypassGovernance {
+		slog.Info("dry-run: DeleteObject", "bucket", bucket, "key", key, "BypassGovernanceRetention", ls.BypassGovernance)
+	}
+	// the end of a short retention is awaited and then the object version is deleted for good
+	if ls.WaitExpiry {
+		slog.Info("dry-run: GetObjectRetention, until the retention has ended", "bucket", bucket, "key", key,
+			"RetainUntilDate", retainUntil)
+		slog.Info("dry-run: DeleteObject", "bucket", bucket, "key", key, "versionId", "<the uploaded version>",
+			"BypassGovernanceRetention", false)
+	}
+
+}
+
+func logDryRunCleanup(bucket string, skipCreate bool, keys []string, ls lockSettings) {
+
+	// the cleanup keeps the object versions under a legal hold or a COMPLIANCE retention, and then the bucket as well
+
+	if ls.LegalHold || ls.Mode == types.ObjectLockModeCompliance {
+		slog.Info("dry-run: cleanup keeps the locked object versions and the bucket", "bucket", bucket,
+			"ObjectLockMode", ls.Mode, "legalHold", ls.LegalHold)
+		return
+	}
+	for _, key := range keys {
+		slog.Info("dry-run: cleanup - DeleteObject", "bucket", bucket, "key", key,
+			"BypassGovernanceRetention", ls.Mode == types.ObjectLockModeGovernance)
+	}
+	if !skipCreate {
+		slog.Info("dry-run: cleanup - DeleteBucket, only if the run created it", "bucket", bucket)
+	}
+
+}
@@ -0,0 +1,178 @@
+package objectlock
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// dryRunCalls returns the messages logged by the dry-run of a single file, iris.csv unless cfg has another source
+func dryRunCalls(t *testing.T, cfg Config, ls lockSettings) []string {
+
+	t.Helper()
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	ls.RetainUntil = time.Date(2030, 1, 31, 12, 0, 0, 0, time.UTC)
+	retention := &types.DefaultRetention{Mode: types.ObjectLockRetentionModeGovernance, Days: 2}
+	cfg.Bucket = "test-wormbucket"
+	if cfg.Filename == "" && cfg.URL == "" {
+		cfg.Filename, cfg.Key = "../iris.csv", "iris.csv"
+	}
+	cfg.Versions = max(cfg.Versions, 1)
+	err := logDryRun(&cfg, false, retention, false, ls)
+	if err != nil {
+		t.Fatalf("logDryRun() error = %v", err)
+	}
+	var calls []string
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var line struct{ Msg string }
+		if err := dec.Decode(&line); err != nil {
+			t.Fatal(err)
+		}
+		calls = append(calls, line.Msg)
+	}
+	return calls
+
+}
+
+func TestLogDryRunForceRecreate(t *testing.T) {
+
+	// the recreation lists the object versions and deletes the empty bucket before the CreateBucket
+
+	calls := dryRunCalls(t, Config{ForceRecreate: true}, lockSettings{Mode: types.ObjectLockModeGovernance})
+	list := slices.Index(calls, "dry-run: ListObjectVersions")
+	del := slices.Index(calls, "dry-run: DeleteBucket, only if the bucket is empty")
+	create := slices.Index(calls, "dry-run: CreateBucket")
+	if list < 0 || del < 0 || !(list < del && del < create) {
+		t.Errorf("logDryRun() calls = %q, want ListObjectVersions and DeleteBucket before CreateBucket", calls)
+	}
+
+	calls = dryRunCalls(t, Config{}, lockSettings{Mode: types.ObjectLockModeGovernance})
+	if slices.Contains(calls, "dry-run: ListObjectVersions") {
+		t.Errorf("logDryRun() calls = %q, want no ListObjectVersions without -force-recreate", calls)
+	}
+
+}
+
+func TestLogDryRunVersions(t *testing.T) {
+
+	// each version is a PutObject of its own, the cleanup deletes each of them and then the bucket
+
+	tests := []struct {
+		name       string
+		versions   int
+		cleanup    bool
+		mode       types.ObjectLockMode
+		wantPut    int
+		wantList   int
+		wantDelete int
+		wantBucket bool
+	}{
+		{name: "single version", versions: 1, mode: types.ObjectLockModeGovernance, wantPut: 1},
+		{name: "three versions", versions: 3, mode: types.ObjectLockModeGovernance, wantPut: 3, wantList: 1},
+		{name: "three versions with cleanup", versions: 3, cleanup: true, mode: types.ObjectLockModeGovernance, wantPut: 3, wantList: 1, wantDelete: 3, wantBucket: true},
+		{name: "compliance with cleanup", versions: 2, cleanup: true, mode: types.ObjectLockModeCompliance, wantPut: 2, wantList: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := dryRunCalls(t, Config{Cleanup: tt.cleanup, Versions: tt.versions}, lockSettings{Mode: tt.mode})
+			count := func(msg string) int {
+				n := 0
+				for _, call := range calls {
+					if call == msg {
+						n++
+					}
+				}
+				return n
+			}
+			if got := count("dry-run: PutObject"); got != tt.wantPut {
+				t.Errorf("logDryRun() logged %d PutObject calls, want %d", got, tt.wantPut)
+			}
+			if got := count("dry-run: ListObjectVersions"); got != tt.wantList {
+				t.Errorf("logDryRun() logged %d ListObjectVersions calls, want %d", got, tt.wantList)
+			}
+			if got := count("dry-run: cleanup - DeleteObject"); got != tt.wantDelete {
+				t.Errorf("logDryRun() logged %d cleanup DeleteObject calls, want %d", got, tt.wantDelete)
+			}
+			if got := slices.Contains(calls, "dry-run: cleanup - DeleteBucket, only if the run created it"); got != tt.wantBucket {
+				t.Errorf("logDryRun() logged the cleanup DeleteBucket = %t, want %t", got, tt.wantBucket)
+			}
+		})
+	}
+
+}
+
+func TestLogDryRunWaitExpiry(t *testing.T) {
+
+	// the destructive delete after the end of the retention is part of the preview
+
+	calls := dryRunCalls(t, Config{}, lockSettings{Mode: types.ObjectLockModeGovernance, WaitExpiry: true})
+	put := slices.Index(calls, "dry-run: PutObject")
+	wait := slices.Index(calls, "dry-run: GetObjectRetention, until the retention has ended")
+	del := slices.Index(calls, "dry-run: DeleteObject")
+	if put < 0 || wait < put || del < wait {
+		t.Errorf("logDryRun() calls = %q, want PutObject, the wait and DeleteObject", calls)
+	}
+
+}
+
+func TestLogDryRunLockSettings(t *testing.T) {
+
+	// each branch of lockFile on the lock settings has its API calls in the preview, iris.csv has 3975 bytes
+
+	tests := []struct {
+		name string
+		cfg  Config
+		ls   lockSettings
+		want []string
+		not  []string
+	}{
+		{
+			name: "verification",
+			want: []string{"dry-run: PutObject", "dry-run: HeadObject", "dry-run: GetObjectRetention", "dry-run: GetObjectLegalHold"},
+			not:  []string{"dry-run: GetObjectAttributes", "dry-run: GetObject", "dry-run: CreateMultipartUpload"},
+		},
+		{
+			name: "attributes and download",
+			ls:   lockSettings{VerifyAPI: verifyAttributes, VerifyDownload: true},
+			want: []string{"dry-run: GetObjectAttributes", "dry-run: GetObject"},
+		},
+		{
+			name: "multipart upload",
+			ls:   lockSettings{MultipartThreshold: 1000, PartSize: 1000},
+			want: []string{"dry-run: CreateMultipartUpload", "dry-run: UploadPart", "dry-run: CompleteMultipartUpload"},
+			not:  []string{"dry-run: PutObject"},
+		},
+		{
+			name: "download of a URL",
+			cfg:  Config{URL: "https://artifacts.example.com/iris.csv", Key: "iris.csv"},
+			ls:   lockSettings{MultipartThreshold: 1000},
+			want: []string{"dry-run: HTTP GET", "dry-run: PutObject, or a multipart upload if the content has the threshold size"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.ls.Mode = types.ObjectLockModeGovernance
+			calls := dryRunCalls(t, tt.cfg, tt.ls)
+			for _, msg := range tt.want {
+				if !slices.Contains(calls, msg) {
+					t.Errorf("logDryRun() calls = %q, want %q", calls, msg)
+				}
+			}
+			for _, msg := range tt.not {
+				if slices.Contains(calls, msg) {
+					t.Errorf("logDryRun() calls = %q, want no %q", calls, msg)
+				}
+			}
+		})
+	}
+
+}
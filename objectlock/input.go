@@ -0,0 +1,246 @@
+package objectlock
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func parseRetainUntil(value string, now time.Time) (time.Time, error) {
+
+	// calculate a future date for the retention period of 1 day - S3 expects a UTC instant
+
+	if value == "" {
+		return now.AddDate(0, 0, 1), nil
+	}
+	rt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid retention date %q [-retain-until 2030-01-31T00:00:00Z]", value)
+	}
+	if !rt.After(now) {
+		return time.Time{}, fmt.Errorf("the retention date %s is in the past [-retain-until]", value)
+	}
+	return rt.UTC(), nil
+
+}
+
+func parseRetainDuration(value string) (time.Duration, error) {
+
+	// a duration of time.ParseDuration with days as an extension, e.g. 30d or 1d12h - a day has 24 hours
+
+	var d time.Duration
+	rest := value
+	if days, after, ok := strings.Cut(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid retention period %q [-retain 72h] [-retain 30d]", value)
+		}
+		d, rest = time.Duration(n)*24*time.Hour, after
+	}
+	if rest != "" {
+		hours, err := time.ParseDuration(rest)
+		if err != nil {
+			return 0, fmt.Errorf("invalid retention period %q [-retain 72h] [-retain 30d]", value)
+		}
+		d += hours
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("the retention period %q must be positive [-retain]", value)
+	}
+	return d, nil
+
+}
+
+func detectContentType(file io.ReadSeeker) (string, error) {
+
+	// only the first 512 bytes are considered for the content type detection
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+
+	// rewind the file for the upload
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	// the detection reports text/plain for no content at all, but an empty file has no type
+	if n == 0 {
+		return "application/octet-stream", nil
+	}
+	return http.DetectContentType(head[:n]), nil
+
+}
+
+func getMD5Hash(file io.ReadSeeker) (string, error) {
+
+	// calculate the md5hash value of the already opened file - a second open could see a changed file
+
+	hasher := md5.New()
+	_, err := io.Copy(hasher, file)
+	if err != nil {
+		return "", err
+	}
+
+	// rewind the file for the upload
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	sum := hasher.Sum(nil)
+
+	// the hash value must be base64 encoded to be accepted by AWS
+	return base64.StdEncoding.EncodeToString(sum), nil
+
+}
+
+func generateBucketName(now time.Time) (string, error) {
+
+	// a unique name for throwaway test buckets within the S3 naming rules: lowercase, 3-63 characters, no underscores
+
+	random := make([]byte, 4)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", err
+	}
+	return "objectlock-test-" + now.UTC().Format("20060102-150405") + "-" + hex.EncodeToString(random), nil
+
+}
+
+func validateBucketName(name string) error {
+
+	// check the naming rules of S3 before the bucket creation, AWS reports a violation only with an opaque error
+
+	if len(name) < 3 || len(name) > 63 {
+		return fmt.Errorf("the bucket name %q has %d characters, it must have 3 to 63 [-b BUCKET]", name, len(name))
+	}
+	for i, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.') {
+			return fmt.Errorf("the bucket name %q contains %q at position %d, only lowercase letters, digits, hyphens and dots are allowed [-b BUCKET]", name, r, i+1)
+		}
+	}
+	if first, last := name[0], name[len(name)-1]; first == '-' || first == '.' || last == '-' || last == '.' {
+		return fmt.Errorf("the bucket name %q must begin and end with a letter or a digit [-b BUCKET]", name)
+	}
+	if strings.Contains(name, "..") {
+		return fmt.Errorf("the bucket name %q contains consecutive dots [-b BUCKET]", name)
+	}
+	if net.ParseIP(name) != nil {
+		return fmt.Errorf("the bucket name %q must not be formatted as an IP address [-b BUCKET]", name)
+	}
+	// the prefixes and suffixes reserved by AWS for other purposes
+	for _, prefix := range []string{"xn--", "sthree-"} {
+		if strings.HasPrefix(name, prefix) {
+			return fmt.Errorf("the bucket name %q uses the reserved prefix %s [-b BUCKET]", name, prefix)
+		}
+	}
+	for _, suffix := range []string{"-s3alias", "--ol-s3"} {
+		if strings.HasSuffix(name, suffix) {
+			return fmt.Errorf("the bucket name %q uses the reserved suffix %s [-b BUCKET]", name, suffix)
+		}
+	}
+	return nil
+
+}
+
+func parseRetentionMode(mode string) (types.ObjectLockRetentionMode, error) {
+
+	// map the mode of the input argument to the Object Lock retention mode
+
+	switch strings.ToLower(mode) {
+	case "governance":
+		return types.ObjectLockRetentionModeGovernance, nil
+	case "compliance":
+		return types.ObjectLockRetentionModeCompliance, nil
+	}
+	return "", fmt.Errorf("invalid retention mode %q, expected governance or compliance", mode)
+
+}
+
+func parseACL(value string) (types.ObjectCannedACL, types.BucketCannedACL, error) {
+
+	// a canned ACL of the objects, which is applied to the bucket too if S3 knows it for buckets, e.g. private
+	// an empty value sends no ACL - S3 keeps the objects and the bucket private
+
+	if value == "" {
+		return "", "", nil
+	}
+	acl := types.ObjectCannedACL(strings.ToLower(value))
+	if !slices.Contains(acl.Values(), acl) {
+		return "", "", fmt.Errorf("invalid canned ACL %q, expected one of %v [-acl ACL]", value, acl.Values())
+	}
+	bucketACL := types.BucketCannedACL(acl)
+	if !slices.Contains(bucketACL.Values(), bucketACL) {
+		bucketACL = ""
+	}
+	return acl, bucketACL, nil
+
+}
+
+func parseStorageClass(value string) (types.StorageClass, error) {
+
+	// an empty value keeps the default storage class of S3, STANDARD
+
+	if value == "" {
+		return "", nil
+	}
+	class := types.StorageClass(strings.ToUpper(value))
+	if !slices.Contains(class.Values(), class) {
+		return "", fmt.Errorf("invalid storage class %q, expected one of %v [-storage-class CLASS]", value, class.Values())
+	}
+	return class, nil
+
+}
+
+func parseServerSideEncryption(sse string, kmsKeyID string) (types.ServerSideEncryption, error) {
+
+	// an empty value keeps the default encryption of the bucket
+
+	var encryption types.ServerSideEncryption
+	switch strings.ToLower(sse) {
+	case "":
+	case "aes256":
+		encryption = types.ServerSideEncryptionAes256
+	case "aws:kms":
+		encryption = types.ServerSideEncryptionAwsKms
+	default:
+		return "", fmt.Errorf("invalid server-side encryption %q [-sse AES256|aws:kms]", sse)
+	}
+	if kmsKeyID != "" && encryption != types.ServerSideEncryptionAwsKms {
+		return "", fmt.Errorf("the KMS key [-kms-key-id KEY] requires the server-side encryption [-sse aws:kms]")
+	}
+	return encryption, nil
+
+}
+
+func newDefaultRetention(mode types.ObjectLockRetentionMode, days int, years int) (*types.DefaultRetention, error) {
+
+	// AWS accepts either days or years for the default retention, but never both
+
+	if days < 0 || years < 0 {
+		return nil, fmt.Errorf("the retention period must be positive [-retention-days DAYS | -retention-years YEARS]")
+	}
+	if days != 0 && years != 0 {
+		return nil, fmt.Errorf("you can only supply one of [-retention-days DAYS] or [-retention-years YEARS]")
+	}
+	if days == 0 && years == 0 {
+		days = 2
+	}
+	return &types.DefaultRetention{Mode: mode, Days: int32(days), Years: int32(years)}, nil
+
+}
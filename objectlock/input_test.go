@@ -0,0 +1,139 @@
+package objectlock
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGetMD5Hash(t *testing.T) {
+
+	// S3 expects the Content-MD5 header as the base64 encoded digest, a hex digest is rejected with InvalidDigest
+	// a missing file is no case of getMD5Hash anymore, lockFile opens the file once with os.Open and fails there
+
+	iris, err := os.ReadFile("../iris.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		content []byte
+		want    string
+	}{
+		{name: "known file", content: iris, want: "NBo7UkTyEygrewkgtynFkg=="},
+		{name: "empty", content: nil, want: "1B2M2Y8AsgTpgAmY7PhCfg=="},
+		{name: "text", content: []byte("The quick brown fox jumps over the lazy dog"), want: "nhB9nTcrtoJr2B01QqQZ1g=="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := bytes.NewReader(tt.content)
+			got, err := getMD5Hash(file)
+			if err != nil {
+				t.Fatalf("getMD5Hash() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("getMD5Hash() = %q, want %q", got, tt.want)
+			}
+
+			// the base64 form of the 16 bytes has 24 characters, the hex form 32
+			sum, err := base64.StdEncoding.DecodeString(got)
+			if err != nil || len(sum) != 16 {
+				t.Errorf("getMD5Hash() = %q is no base64 encoded MD5 digest", got)
+			}
+
+			// the upload reads the same reader afterwards, so it must start at the first byte again
+			rest, err := io.ReadAll(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(rest, tt.content) {
+				t.Errorf("getMD5Hash() left the reader at offset %d, want 0", len(tt.content)-len(rest))
+			}
+		})
+	}
+
+}
+
+func TestDetectContentType(t *testing.T) {
+
+	// only the first 512 bytes are sniffed, the reader must be rewound for the upload in any case
+
+	text := bytes.Repeat([]byte("sepal_length,sepal_width\n"), 30)
+	tests := []struct {
+		name    string
+		content []byte
+		want    string
+	}{
+		{name: "pdf", content: []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n1 0 obj\n"), want: "application/pdf"},
+		{name: "text", content: []byte("hello, object lock\n"), want: "text/plain; charset=utf-8"},
+		{name: "unknown binary", content: []byte{0x00, 0x01, 0x02, 0x03, 0xfe, 0xff, 0x10, 0x7f}, want: "application/octet-stream"},
+		{name: "binary after 512 bytes of text", content: append(text[:512:512], 0x00, 0x01, 0x02, 0xff), want: "text/plain; charset=utf-8"},
+		{name: "empty", content: nil, want: "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := bytes.NewReader(tt.content)
+			got, err := detectContentType(file)
+			if err != nil {
+				t.Fatalf("detectContentType() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("detectContentType() = %q, want %q", got, tt.want)
+			}
+			offset, err := file.Seek(0, io.SeekCurrent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if offset != 0 {
+				t.Errorf("detectContentType() left the reader at offset %d, want 0", offset)
+			}
+		})
+	}
+
+}
+
+func TestValidateBucketName(t *testing.T) {
+
+	// each violation must be reported by its own rule, an empty want accepts the name
+
+	tests := []struct {
+		name   string
+		bucket string
+		want   string
+	}{
+		{name: "valid", bucket: "objectlock-test-20240131-120000-1a2b3c4d", want: ""},
+		{name: "valid with dots", bucket: "my.worm.bucket", want: ""},
+		{name: "too short", bucket: "ab", want: "it must have 3 to 63"},
+		{name: "too long", bucket: strings.Repeat("a", 64), want: "it must have 3 to 63"},
+		{name: "uppercase", bucket: "WormBucket", want: "only lowercase letters"},
+		{name: "underscore", bucket: "worm_bucket", want: "only lowercase letters"},
+		{name: "leading hyphen", bucket: "-wormbucket", want: "begin and end with a letter or a digit"},
+		{name: "trailing hyphen", bucket: "wormbucket-", want: "begin and end with a letter or a digit"},
+		{name: "leading dot", bucket: ".wormbucket", want: "begin and end with a letter or a digit"},
+		{name: "trailing dot", bucket: "wormbucket.", want: "begin and end with a letter or a digit"},
+		{name: "consecutive dots", bucket: "worm..bucket", want: "consecutive dots"},
+		{name: "ip address", bucket: "192.168.1.1", want: "formatted as an IP address"},
+		{name: "xn-- prefix", bucket: "xn--wormbucket", want: "reserved prefix xn--"},
+		{name: "sthree- prefix", bucket: "sthree-wormbucket", want: "reserved prefix sthree-"},
+		{name: "-s3alias suffix", bucket: "wormbucket-s3alias", want: "reserved suffix -s3alias"},
+		{name: "--ol-s3 suffix", bucket: "wormbucket--ol-s3", want: "reserved suffix --ol-s3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBucketName(tt.bucket)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("validateBucketName(%q) error = %v, want nil", tt.bucket, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("validateBucketName(%q) error = %v, want an error with %q", tt.bucket, err, tt.want)
+			}
+		})
+	}
+
+}
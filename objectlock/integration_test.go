@@ -0,0 +1,140 @@
+//go:build integration
+
+package objectlock
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/testcontainers/testcontainers-go/modules/minio"
+)
+
+// minioImage is the MinIO release of the integration test, Object Lock needs no further setup of the server
+const minioImage = "minio/minio:RELEASE.2024-01-16T16-07-38Z"
+
+func minioEndpoint(t *testing.T) (endpoint string, external bool) {
+
+	// a MinIO container of the test, or an already running S3 compatible endpoint with OBJECTLOCK_TEST_ENDPOINT
+	// and the credentials of the AWS environment variables, e.g. where no Docker is available
+
+	if endpoint := os.Getenv("OBJECTLOCK_TEST_ENDPOINT"); endpoint != "" {
+		return endpoint, true
+	}
+	ctx := context.Background()
+	container, err := minio.Run(ctx, minioImage)
+	if err != nil {
+		t.Fatalf("start MinIO: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := container.Terminate(context.Background()); err != nil {
+			t.Errorf("terminate MinIO: %v", err)
+		}
+	})
+	address, err := container.ConnectionString(ctx)
+	if err != nil {
+		t.Fatalf("MinIO address: %v", err)
+	}
+	t.Setenv("AWS_ACCESS_KEY_ID", container.Username)
+	t.Setenv("AWS_SECRET_ACCESS_KEY", container.Password)
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	return "http://" + address, false
+
+}
+
+func TestRunIntegration(t *testing.T) {
+
+	// the complete workflow against a real endpoint: a locked bucket with a default retention and a locked object
+	// COMPLIANCE only in the container of the test, an external endpoint must not keep undeletable objects
+
+	endpoint, external := minioEndpoint(t)
+	mode := types.ObjectLockRetentionModeCompliance
+	if external {
+		mode = types.ObjectLockRetentionModeGovernance
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	bucket, err := generateBucketName(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cfg.Region = "us-east-1"
+	cfg.Endpoint = endpoint
+	cfg.Bucket = bucket
+	cfg.Mode = strings.ToLower(string(mode))
+	cfg.RetentionDays = 1
+	cfg.ObjectMode = cfg.Mode
+	cfg.Filename = "../iris.csv"
+	client, err := cfg.newClient(ctx, &Result{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if external {
+		t.Cleanup(func() { removeBucket(t, client, bucket) })
+	}
+	res, err := Run(ctx, cfg)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !res.Created {
+		t.Errorf("Run() created no bucket %s", bucket)
+	}
+
+	// read the settings back from the endpoint, not from the Result of the run
+	lock, err := getObjectLockConfiguration(ctx, client, bucket)
+	if err != nil {
+		t.Fatalf("GetObjectLockConfiguration: %v", err)
+	}
+	if lock.ObjectLockEnabled != types.ObjectLockEnabledEnabled {
+		t.Errorf("ObjectLockEnabled = %q, want %q", lock.ObjectLockEnabled, types.ObjectLockEnabledEnabled)
+	}
+	if lock.Rule == nil || lock.Rule.DefaultRetention == nil {
+		t.Fatal("the bucket has no default retention")
+	}
+	if got := lock.Rule.DefaultRetention; got.Mode != mode || got.Days != 1 {
+		t.Errorf("DefaultRetention = %s %d days, want %s 1 days", got.Mode, got.Days, mode)
+	}
+
+	retention, err := getObjectRetention(ctx, client, bucket, res.Key, res.VersionID)
+	if err != nil {
+		t.Fatalf("GetObjectRetention: %v", err)
+	}
+	if retention.Mode != mode {
+		t.Errorf("Retention.Mode = %q, want %q", retention.Mode, mode)
+	}
+	if until := aws.ToTime(retention.RetainUntilDate); !until.After(time.Now()) {
+		t.Errorf("Retention.RetainUntilDate = %s, want a date in the future", until)
+	}
+
+}
+
+func removeBucket(t *testing.T, client S3API, bucket string) {
+
+	// delete the GOVERNANCE object versions with the bypass and then the bucket of the test
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	out, err := client.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{Bucket: &bucket})
+	if err != nil {
+		t.Errorf("list the object versions of %s: %v", bucket, err)
+		return
+	}
+	for _, v := range out.Versions {
+		err := deleteObjectVersion(ctx, client, bucket, aws.ToString(v.Key), aws.ToString(v.VersionId), true)
+		if err != nil {
+			t.Errorf("delete %s version %s: %v", aws.ToString(v.Key), aws.ToString(v.VersionId), err)
+		}
+	}
+	_, err = client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: &bucket})
+	if err != nil {
+		t.Errorf("delete bucket %s: %v", bucket, err)
+	}
+
+}
@@ -0,0 +1,182 @@
+package objectlock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// filterNone selects the objects without retention in the list
+const filterNone = "NONE"
+
+func parseFilterMode(value string) (string, error) {
+
+	// an empty filter lists all objects, none the objects without retention
+
+	switch mode := strings.ToUpper(value); mode {
+	case "", filterNone, string(types.ObjectLockModeGovernance), string(types.ObjectLockModeCompliance):
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid filter mode %q, expected governance, compliance or none [-filter-mode MODE]", value)
+
+}
+
+func matchesFilterMode(mode string, filterMode string) bool {
+
+	switch filterMode {
+	case "":
+		return true
+	case filterNone:
+		return mode == ""
+	}
+	return mode == filterMode
+
+}
+
+func listLockedObjects(ctx context.Context, client S3API, bucket string, prefix string, filterMode string) ([]ObjectResult, error) {
+
+	// collect the retention and the legal hold of the latest version of each object - an audit of the bucket
+	// the paginator follows the continuation tokens, so buckets with more than 1000 objects are listed completely
+
+	var results []ObjectResult
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{Bucket: &bucket, Prefix: optionalString(prefix)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return results, fmt.Errorf("list objects of %s: %w", bucket, err)
+		}
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			or := ObjectResult{Key: key}
+
+			ret, err := getObjectRetention(ctx, client, bucket, key, "")
+			if err != nil && !isNoLockConfiguration(err) {
+				return results, fmt.Errorf("get retention of %s: %w", key, err)
+			}
+			if ret != nil {
+				or.ObjectLockMode = string(ret.Mode)
+				or.RetainUntilDate = ret.RetainUntilDate
+			}
+			// the legal hold is only requested for the objects of the filter
+			if !matchesFilterMode(or.ObjectLockMode, filterMode) {
+				continue
+			}
+
+			status, err := getLegalHold(ctx, client, bucket, key, "")
+			if err != nil && !isNoLockConfiguration(err) {
+				return results, fmt.Errorf("get legal hold of %s: %w", key, err)
+			}
+			if status == "" {
+				status = types.ObjectLockLegalHoldStatusOff
+			}
+			or.LegalHold = string(status)
+			results = append(results, or)
+		}
+	}
+	return results, nil
+
+}
+
+func listObjectVersions(ctx context.Context, client S3API, bucket string, key string) ([]ObjectResult, error) {
+
+	// collect the retention and the legal hold of each version of the key, the latest version first
+	// the prefix also lists the keys that start with the key, they are skipped
+
+	var results []ObjectResult
+	paginator := s3.NewListObjectVersionsPaginator(client, &s3.ListObjectVersionsInput{Bucket: &bucket, Prefix: &key})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return results, fmt.Errorf("list object versions of %s: %w", key, err)
+		}
+		for _, v := range page.Versions {
+			if aws.ToString(v.Key) != key {
+				continue
+			}
+			or := ObjectResult{Key: key, VersionID: aws.ToString(v.VersionId)}
+
+			ret, err := getObjectRetention(ctx, client, bucket, key, or.VersionID)
+			if err != nil && !isNoLockConfiguration(err) {
+				return results, fmt.Errorf("get retention of %s version %s: %w", key, or.VersionID, err)
+			}
+			if ret != nil {
+				or.ObjectLockMode = string(ret.Mode)
+				or.RetainUntilDate = ret.RetainUntilDate
+			}
+			status, err := getLegalHold(ctx, client, bucket, key, or.VersionID)
+			if err != nil && !isNoLockConfiguration(err) {
+				return results, fmt.Errorf("get legal hold of %s version %s: %w", key, or.VersionID, err)
+			}
+			if status == "" {
+				status = types.ObjectLockLegalHoldStatusOff
+			}
+			or.LegalHold = string(status)
+			results = append(results, or)
+		}
+	}
+	return results, nil
+
+}
+
+func isNoLockConfiguration(err error) bool {
+
+	// S3 reports an object without retention or legal hold as an error, which is no failure of the audit
+
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchObjectLockConfiguration"
+
+}
+
+func printObjectTable(w io.Writer, results []ObjectResult) error {
+
+	// print the objects as aligned columns, an object without retention shows a dash
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tMODE\tRETAIN UNTIL\tREMAINING\tLEGAL HOLD")
+	now := time.Now()
+	for _, or := range results {
+		mode, until, remaining := "-", "-", "-"
+		if or.ObjectLockMode != "" {
+			mode = or.ObjectLockMode
+		}
+		if or.RetainUntilDate != nil {
+			until = or.RetainUntilDate.UTC().Format(time.RFC3339)
+			remaining = formatRemaining(*or.RetainUntilDate, now)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", or.Key, mode, until, remaining, or.LegalHold)
+	}
+	return tw.Flush()
+
+}
+
+func printObjectSummary(w io.Writer, results []ObjectResult) {
+
+	// count the objects of the audit by their Object Lock
+
+	var governance, compliance, none, legalHold int
+	for _, or := range results {
+		switch or.ObjectLockMode {
+		case string(types.ObjectLockModeGovernance):
+			governance++
+		case string(types.ObjectLockModeCompliance):
+			compliance++
+		default:
+			none++
+		}
+		if or.LegalHold == string(types.ObjectLockLegalHoldStatusOn) {
+			legalHold++
+		}
+	}
+	fmt.Fprintf(w, "%d objects: %d COMPLIANCE, %d GOVERNANCE, %d without retention, %d with legal hold\n",
+		len(results), compliance, governance, none, legalHold)
+
+}
@@ -0,0 +1,149 @@
+package objectlock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// apiError logs a failed S3 call with its error code, message and request id,
+// which correlate the failure with CloudTrail and support tickets
+type apiError struct {
+	err error
+}
+
+func (e apiError) LogValue() slog.Value {
+
+	// other errors, e.g. of the local file, have no details beyond their message - an empty group is omitted
+
+	var ae smithy.APIError
+	if !errors.As(e.err, &ae) {
+		return slog.GroupValue()
+	}
+	attrs := []slog.Attr{slog.String("code", ae.ErrorCode()), slog.String("message", ae.ErrorMessage())}
+	var oe *smithy.OperationError
+	if errors.As(e.err, &oe) {
+		attrs = append(attrs, slog.String("operation", oe.Operation()))
+	}
+	var re *awshttp.ResponseError
+	if errors.As(e.err, &re) {
+		attrs = append(attrs, slog.Int("statusCode", re.HTTPStatusCode()), slog.String("requestId", re.ServiceRequestID()))
+	}
+	// AWS support asks for the extended request id of S3 as well
+	var s3e s3.ResponseError
+	if errors.As(e.err, &s3e) && s3e.ServiceHostID() != "" {
+		attrs = append(attrs, slog.String("hostId", s3e.ServiceHostID()))
+	}
+	return slog.GroupValue(attrs...)
+
+}
+
+// ErrorValue logs the error code, message and request id of a failed S3 call as a group of attributes
+func ErrorValue(err error) slog.LogValuer {
+
+	return apiError{err}
+
+}
+
+// APIErrorDetails returns the error code and the request id of a failed S3 call for the JSON output
+func APIErrorDetails(err error) (code string, requestID string) {
+
+	var ae smithy.APIError
+	if errors.As(err, &ae) {
+		code = ae.ErrorCode()
+	}
+	var re *awshttp.ResponseError
+	if errors.As(err, &re) {
+		requestID = re.ServiceRequestID()
+	}
+	return code, requestID
+
+}
+
+func addAPICallLogging(stack *middleware.Stack) error {
+
+	// log the input and the latency of each S3 API call at the debug level
+
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("LogAPICall",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (
+			middleware.InitializeOutput, middleware.Metadata, error,
+		) {
+			operation := awsmiddleware.GetOperationName(ctx)
+			slog.Debug("API call", "operation", operation, "input", describeInput(in.Parameters))
+
+			start := time.Now()
+			out, metadata, err := next.HandleInitialize(ctx, in)
+			if err != nil {
+				slog.Debug("API call failed", "operation", operation, "latency", time.Since(start), "error", err)
+			} else {
+				slog.Debug("API call done", "operation", operation, "latency", time.Since(start))
+			}
+			return out, metadata, err
+		}), middleware.After)
+
+}
+
+// debugClient logs the headers of the HTTP requests and responses of the S3 client, the credentials are redacted
+type debugClient struct {
+	next s3.HTTPClient
+}
+
+func (c *debugClient) Do(req *http.Request) (*http.Response, error) {
+
+	// the headers reveal what the SDK sends and what the backend returns, e.g. x-amz-object-lock-retain-until-date
+
+	slog.Info("HTTP request", "method", req.Method, "url", req.URL.String(), "headers", headerValue(req.Header))
+	resp, err := c.next.Do(req)
+	if err != nil {
+		slog.Info("HTTP request failed", "method", req.Method, "url", req.URL.String(), "error", err)
+		return nil, err
+	}
+	slog.Info("HTTP response", "status", resp.Status, "headers", headerValue(resp.Header))
+	return resp, nil
+
+}
+
+func headerValue(header http.Header) slog.Value {
+
+	// the headers as a group in the order of their names - the signature and the session token stay secret
+
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	attrs := make([]slog.Attr, 0, len(names))
+	for _, name := range names {
+		value := strings.Join(header.Values(name), ", ")
+		if name == "Authorization" || name == "X-Amz-Security-Token" {
+			value = "REDACTED"
+		}
+		attrs = append(attrs, slog.String(name, value))
+	}
+	return slog.GroupValue(attrs...)
+
+}
+
+func describeInput(params interface{}) string {
+
+	// the input structs consist of pointers, so their JSON form is much more readable than %v
+
+	b, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Sprintf("%+v", params)
+	}
+	return string(b)
+
+}
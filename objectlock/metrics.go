@@ -0,0 +1,50 @@
+package objectlock
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// APICall is the wall-clock duration of a single S3 API call, including its retries
+type APICall struct {
+	Operation  string  `json:"operation"`
+	DurationMs float64 `json:"durationMs"`
+	Failed     bool    `json:"failed,omitempty"`
+}
+
+// callMetrics collects the API calls of a run - the uploads of a directory run concurrently
+type callMetrics struct {
+	mu    sync.Mutex
+	calls *[]APICall
+}
+
+func (m *callMetrics) addAPICallMetrics(stack *middleware.Stack) error {
+
+	// measure each S3 API call for the comparison of the endpoints, e.g. AWS vs MinIO vs Ceph
+	// the operation name is only known after the service metadata middleware of the Initialize step
+
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("APICallMetrics",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (
+			middleware.InitializeOutput, middleware.Metadata, error,
+		) {
+			start := time.Now()
+			out, metadata, err := next.HandleInitialize(ctx, in)
+			call := APICall{
+				Operation:  awsmiddleware.GetOperationName(ctx),
+				DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+				Failed:     err != nil,
+			}
+			slog.Info("API call latency", "operation", call.Operation, "durationMs", call.DurationMs, "failed", call.Failed)
+
+			m.mu.Lock()
+			*m.calls = append(*m.calls, call)
+			m.mu.Unlock()
+			return out, metadata, err
+		}), middleware.After)
+
+}
@@ -0,0 +1,746 @@
+// Package objectlock runs the S3 Object Lock workflow of goS3ObjectLockTest: create a locked bucket, upload an object under retention and verify its protection
+package objectlock
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// S3API contains exactly the operations of the S3 service client used for the Object Lock test,
+// so the client can be replaced by a fake implementation
+type S3API interface {
+	CreateBucket(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error)
+	HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error)
+	GetBucketVersioning(ctx context.Context, params *s3.GetBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.GetBucketVersioningOutput, error)
+	GetBucketLocation(ctx context.Context, params *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLocationOutput, error)
+	PutObjectLockConfiguration(ctx context.Context, params *s3.PutObjectLockConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutObjectLockConfigurationOutput, error)
+	GetObjectLockConfiguration(ctx context.Context, params *s3.GetObjectLockConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetObjectLockConfigurationOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+	DeleteBucket(ctx context.Context, params *s3.DeleteBucketInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	GetObjectAttributes(ctx context.Context, params *s3.GetObjectAttributesInput, optFns ...func(*s3.Options)) (*s3.GetObjectAttributesOutput, error)
+	GetObjectRetention(ctx context.Context, params *s3.GetObjectRetentionInput, optFns ...func(*s3.Options)) (*s3.GetObjectRetentionOutput, error)
+	PutObjectRetention(ctx context.Context, params *s3.PutObjectRetentionInput, optFns ...func(*s3.Options)) (*s3.PutObjectRetentionOutput, error)
+	PutObjectLegalHold(ctx context.Context, params *s3.PutObjectLegalHoldInput, optFns ...func(*s3.Options)) (*s3.PutObjectLegalHoldOutput, error)
+	GetObjectLegalHold(ctx context.Context, params *s3.GetObjectLegalHoldInput, optFns ...func(*s3.Options)) (*s3.GetObjectLegalHoldOutput, error)
+	GetBucketReplication(ctx context.Context, params *s3.GetBucketReplicationInput, optFns ...func(*s3.Options)) (*s3.GetBucketReplicationOutput, error)
+}
+
+// the concrete service client satisfies the interface, which also serves the upload manager
+var _ S3API = (*s3.Client)(nil)
+var _ manager.UploadAPIClient = (S3API)(nil)
+
+// uploadOptions are the parameters of a locked object besides its key and content
+type uploadOptions struct {
+	ContentType string
+	ContentMD5  string
+	// empty headers for the download are omitted
+	ContentDisposition string
+	CacheControl       string
+	// the HTTP Expires header for caches - unrelated to the retention of the Object Lock
+	Expires *time.Time
+	// an empty canned ACL is omitted
+	ACL types.ObjectCannedACL
+	// an empty storage class keeps STANDARD
+	StorageClass types.StorageClass
+	// the URL-encoded tag set of the object, e.g. retention-class=legal
+	Tagging string
+	// the user-defined metadata of the object, sent as x-amz-meta-* headers
+	Metadata map[string]string
+	// an empty server-side encryption keeps the default encryption of the bucket
+	ServerSideEncryption types.ServerSideEncryption
+	SSEKMSKeyID          string
+	// an S3 Bucket Key reduces the requests to KMS for aws:kms
+	BucketKeyEnabled bool
+	// with a checksum algorithm the checksum replaces the Content-MD5 header
+	ChecksumAlgorithm types.ChecksumAlgorithm
+	Checksum          string
+	Mode              types.ObjectLockMode
+	RetainUntil       time.Time
+	// objects from this size on are uploaded in parts with the upload manager, 0 disables multipart uploads
+	MultipartThreshold int64
+	// the part size and the parallel part uploads of the upload manager, 0 keeps its defaults
+	PartSize          int64
+	UploadConcurrency int
+}
+
+// the attempts of a CreateBucket while S3 still processes the deletion of a bucket with the same name
+var (
+	createBucketAttempts   = 6
+	createBucketRetryDelay = time.Second
+)
+
+func createLockedBucket(ctx context.Context, client S3API, bucket string, region string, acl types.BucketCannedACL,
+	recreated bool) (created bool, err error) {
+
+	// create the bucket with Object Lock enabled for WORM / archiving purposes
+	// us-east-1 answers the CreateBucket of an owned bucket with 200 OK, so only HeadBucket tells a bucket of a previous run
+	// a just deleted bucket may still be found by HeadBucket, so a recreated bucket is always created
+
+	if !recreated {
+		_, err = client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: &bucket})
+		if err == nil {
+			return false, nil
+		}
+	}
+	// any failure of the HeadBucket has no error code, e.g. a missing bucket or a bucket of another account,
+	// so it is left to the CreateBucket, which reports the cause
+
+	input := &s3.CreateBucketInput{
+		Bucket:                     &bucket,
+		ObjectLockEnabledForBucket: true,
+	}
+	// a new bucket enforces the bucket owner for the objects, which disables ACLs - unless the ownership is set
+	if acl != "" {
+		input.ACL = acl
+		input.ObjectOwnership = types.ObjectOwnershipBucketOwnerPreferred
+	}
+	// outside of us-east-1 S3 rejects a bucket without location constraint with IllegalLocationConstraintException
+	if region != "" && region != "us-east-1" {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(region),
+		}
+	}
+	// S3 answers OperationAborted as long as the deletion of a bucket with the name is pending
+	delay := createBucketRetryDelay
+	for attempt := 1; ; attempt++ {
+		_, err = client.CreateBucket(ctx, input)
+		if !isOperationAborted(err) || attempt == createBucketAttempts {
+			break
+		}
+		slog.Info("CreateBucket - a conflicting operation on the bucket is pending, retrying", "bucket", bucket,
+			"attempt", attempt, "delay", delay)
+		err = sleep(ctx, delay)
+		if err != nil {
+			return false, err
+		}
+		delay *= 2
+	}
+
+	// a bucket of a previous run is no failure, so repeated test runs are possible - e.g. created since the HeadBucket
+	var owned *types.BucketAlreadyOwnedByYou
+	if errors.As(err, &owned) {
+		return false, nil
+	}
+	return err == nil, err
+
+}
+
+func isMultipart(threshold int64, size int64) bool {
+
+	// a threshold of 0 disables the multipart uploads
+
+	return threshold > 0 && size >= threshold
+
+}
+
+func partChecksumAlgorithm(algorithm types.ChecksumAlgorithm) types.ChecksumAlgorithm {
+
+	// the parts of a multipart upload carry the checksum of the upload, CRC32 without one
+
+	if algorithm == "" {
+		return types.ChecksumAlgorithmCrc32
+	}
+	return algorithm
+
+}
+
+func getBucketVersioning(ctx context.Context, client S3API, bucket string) (types.BucketVersioningStatus, error) {
+
+	// Object Lock requires versioning, S3 enables it with the creation of a lock-enabled bucket
+
+	out, err := client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{
+		Bucket: &bucket,
+	})
+	if err != nil {
+		return "", err
+	}
+	return out.Status, nil
+
+}
+
+func bucketRegion(ctx context.Context, client S3API, bucket string) (string, error) {
+
+	// probe the bucket - only a bucket of another region is redirected, an unknown bucket is left to the command
+	// S3 reports us-east-1 as an empty location constraint and eu-west-1 with its legacy name EU
+
+	_, err := client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: &bucket})
+	if !isWrongRegion(err) {
+		return "", nil
+	}
+	slog.Info("the bucket is not located in the configured region", "bucket", bucket, "error", apiError{err})
+	out, err := client.GetBucketLocation(ctx, &s3.GetBucketLocationInput{Bucket: &bucket})
+	if err != nil {
+		return "", fmt.Errorf("get location of %s: %w", bucket, err)
+	}
+	switch out.LocationConstraint {
+	case "":
+		return "us-east-1", nil
+	case types.BucketLocationConstraintEu:
+		return "eu-west-1", nil
+	}
+	return string(out.LocationConstraint), nil
+
+}
+
+func setDefaultRetention(ctx context.Context, client S3API, bucket string, retention *types.DefaultRetention, token string) error {
+
+	// put the default retention period on the bucket - without a retention Object Lock stays enabled without a rule
+
+	olc := &types.ObjectLockConfiguration{ObjectLockEnabled: types.ObjectLockEnabledEnabled}
+	if retention != nil {
+		olc.Rule = &types.ObjectLockRule{DefaultRetention: retention}
+	}
+	// the token allows to enable Object Lock on an existing bucket that was created without it
+	_, err := client.PutObjectLockConfiguration(ctx, &s3.PutObjectLockConfigurationInput{
+		Bucket:                  &bucket,
+		ObjectLockConfiguration: olc,
+		Token:                   optionalString(token),
+	})
+	return err
+
+}
+
+func hasDefaultRetention(olc *types.ObjectLockConfiguration, retention *types.DefaultRetention) bool {
+
+	// compare the Object Lock settings of the bucket with the desired default retention - nil means no default retention
+
+	if olc == nil || olc.ObjectLockEnabled != types.ObjectLockEnabledEnabled {
+		return false
+	}
+	if olc.Rule == nil || olc.Rule.DefaultRetention == nil {
+		return retention == nil
+	}
+	current := olc.Rule.DefaultRetention
+	return retention != nil && current.Mode == retention.Mode && current.Days == retention.Days && current.Years == retention.Years
+
+}
+
+func getObjectLockConfiguration(ctx context.Context, client S3API, bucket string) (*types.ObjectLockConfiguration, error) {
+
+	// request the Object Lock settings of the bucket - an endpoint may answer without any configuration element
+
+	out, err := client.GetObjectLockConfiguration(ctx, &s3.GetObjectLockConfigurationInput{
+		Bucket: &bucket,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.ObjectLockConfiguration == nil {
+		return &types.ObjectLockConfiguration{}, nil
+	}
+	return out.ObjectLockConfiguration, nil
+
+}
+
+func uploadLockedObject(ctx context.Context, client S3API, bucket string, key string, body io.Reader, size int64, opts uploadOptions) (versionID string, etag string, err error) {
+
+	// upload the content into the bucket - an object with the appropriate Object Lock parameters
+	// the body is streamed, so even large archive files are never held in memory
+	// the ETag is only returned for a single PutObject, the ETag of a multipart upload is no md5hash
+
+	input := &s3.PutObjectInput{
+		Bucket:                    &bucket,
+		Key:                       &key,
+		Body:                      body,
+		ContentLength:             size,
+		ContentType:               &opts.ContentType,
+		ObjectLockMode:            opts.Mode,
+		ObjectLockRetainUntilDate: &opts.RetainUntil,
+		ContentDisposition:        optionalString(opts.ContentDisposition),
+		CacheControl:              optionalString(opts.CacheControl),
+		Expires:                   opts.Expires,
+		ACL:                       opts.ACL,
+		StorageClass:              opts.StorageClass,
+		Tagging:                   optionalString(opts.Tagging),
+		Metadata:                  opts.Metadata,
+		ServerSideEncryption:      opts.ServerSideEncryption,
+		SSEKMSKeyId:               optionalString(opts.SSEKMSKeyID),
+		BucketKeyEnabled:          opts.BucketKeyEnabled,
+	}
+
+	// Object Lock requires either the Content-MD5 header or a checksum of the content - on AWS S3,
+	// S3 compatible storages may reject the header, so an empty md5hash omits it
+	if opts.ChecksumAlgorithm != "" {
+		input.ChecksumAlgorithm = opts.ChecksumAlgorithm
+		switch opts.ChecksumAlgorithm {
+		case types.ChecksumAlgorithmSha256:
+			input.ChecksumSHA256 = &opts.Checksum
+		case types.ChecksumAlgorithmCrc32:
+			input.ChecksumCRC32 = &opts.Checksum
+		case types.ChecksumAlgorithmCrc32c:
+			input.ChecksumCRC32C = &opts.Checksum
+		}
+	} else {
+		input.ContentMD5 = optionalString(opts.ContentMD5)
+	}
+
+	// a single PutObject is fragile for large objects - use a multipart upload instead
+	if isMultipart(opts.MultipartThreshold, size) {
+		// the Content-MD5 and checksum of the whole object are ignored for the parts, but Object Lock
+		// requires an integrity check for each part - so let the parts carry a checksum
+		input.ContentMD5 = nil
+		input.ChecksumSHA256, input.ChecksumCRC32, input.ChecksumCRC32C = nil, nil, nil
+		input.ChecksumAlgorithm = partChecksumAlgorithm(input.ChecksumAlgorithm)
+		uploader := manager.NewUploader(client, func(u *manager.Uploader) {
+			if opts.PartSize > 0 {
+				u.PartSize = opts.PartSize
+			}
+			if opts.UploadConcurrency > 0 {
+				u.Concurrency = opts.UploadConcurrency
+			}
+		})
+		out, err := uploader.Upload(ctx, input)
+		if err != nil {
+			abortCancelledUpload(ctx, client, bucket, key, err)
+			return "", "", err
+		}
+		return aws.ToString(out.VersionID), "", nil
+	}
+
+	// Object Lock is per version, so the version id of the upload identifies the locked object
+	out, err := client.PutObject(ctx, input)
+	if err != nil {
+		return "", "", err
+	}
+	return aws.ToString(out.VersionId), aws.ToString(out.ETag), nil
+
+}
+
+func abortCancelledUpload(ctx context.Context, client S3API, bucket string, key string, err error) {
+
+	// the uploader aborts a failed multipart upload with the cancelled context, which fails in turn
+	// abort it once more without the cancellation, so no billable parts are left behind
+
+	var mu manager.MultiUploadFailure
+	if ctx.Err() == nil || !errors.As(err, &mu) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	uploadID := mu.UploadID()
+	_, err = client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{Bucket: &bucket, Key: &key, UploadId: &uploadID})
+	if err != nil {
+		slog.Warn("AbortMultipartUpload failed, the uploaded parts are kept", "key", key, "uploadId", uploadID, "error", apiError{err})
+		return
+	}
+	slog.Info("AbortMultipartUpload - success!", "key", key, "uploadId", uploadID)
+
+}
+
+func headObject(ctx context.Context, client S3API, bucket string, key string, versionID string,
+	checksumMode types.ChecksumMode) (*s3.HeadObjectOutput, error) {
+
+	// request the metadata of the object version, which includes its Object Lock settings
+	// the checksum of the upload only with the enabled checksum mode - SSE-KMS objects need kms:Decrypt for it
+
+	return client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       &bucket,
+		Key:          &key,
+		VersionId:    optionalString(versionID),
+		ChecksumMode: checksumMode,
+	})
+
+}
+
+func getObjectAttributes(ctx context.Context, client S3API, bucket string, key string, versionID string) (*s3.GetObjectAttributesOutput, error) {
+
+	// request the integrity attributes of the object version in one call - the parts of a multipart upload with their checksums
+	// the Object Lock settings are no object attributes
+
+	return client.GetObjectAttributes(ctx, &s3.GetObjectAttributesInput{
+		Bucket:    &bucket,
+		Key:       &key,
+		VersionId: optionalString(versionID),
+		ObjectAttributes: []types.ObjectAttributes{
+			types.ObjectAttributesEtag,
+			types.ObjectAttributesChecksum,
+			types.ObjectAttributesObjectParts,
+			types.ObjectAttributesStorageClass,
+			types.ObjectAttributesObjectSize,
+		},
+	})
+
+}
+
+func getObjectRetention(ctx context.Context, client S3API, bucket string, key string, versionID string) (*types.ObjectLockRetention, error) {
+
+	// request the retention of the object version - the authoritative per-object view
+
+	out, err := client.GetObjectRetention(ctx, &s3.GetObjectRetentionInput{
+		Bucket:    &bucket,
+		Key:       &key,
+		VersionId: optionalString(versionID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Retention == nil {
+		return &types.ObjectLockRetention{}, nil
+	}
+	return out.Retention, nil
+
+}
+
+func extendRetention(ctx context.Context, client S3API, bucket string, key string, versionID string, until time.Time) (*types.ObjectLockRetention, error) {
+
+	// this wrapper only extends a retention period, it never shortens one - read the current retention first
+	// an earlier date is refused before the PutObjectRetention, the same date keeps the retention as it is
+
+	current, err := getObjectRetention(ctx, client, bucket, key, versionID)
+	if err != nil {
+		return nil, err
+	}
+	if current.RetainUntilDate == nil {
+		return current, fmt.Errorf("the object %s has no retention to extend", key)
+	}
+	if until.Before(*current.RetainUntilDate) {
+		return current, &RetentionShortenedError{Key: key, Current: *current.RetainUntilDate, Requested: until}
+	}
+
+	// the mode stays the same, only the date moves on
+	return current, putObjectRetention(ctx, client, bucket, key, versionID, current.Mode, until)
+
+}
+
+func putObjectRetention(ctx context.Context, client S3API, bucket string, key string, versionID string,
+	mode types.ObjectLockRetentionMode, until time.Time) error {
+
+	// put the retention of the object version as it is - S3 decides whether the change is allowed
+
+	_, err := client.PutObjectRetention(ctx, &s3.PutObjectRetentionInput{
+		Bucket:    &bucket,
+		Key:       &key,
+		VersionId: optionalString(versionID),
+		Retention: &types.ObjectLockRetention{
+			Mode:            mode,
+			RetainUntilDate: aws.Time(until),
+		},
+	})
+	return err
+
+}
+
+func setLegalHold(ctx context.Context, client S3API, bucket string, key string, versionID string, status types.ObjectLockLegalHoldStatus) error {
+
+	// a legal hold protects the object version independently of its retention period
+
+	_, err := client.PutObjectLegalHold(ctx, &s3.PutObjectLegalHoldInput{
+		Bucket:    &bucket,
+		Key:       &key,
+		VersionId: optionalString(versionID),
+		LegalHold: &types.ObjectLockLegalHold{Status: status},
+	})
+	return err
+
+}
+
+func getLegalHold(ctx context.Context, client S3API, bucket string, key string, versionID string) (types.ObjectLockLegalHoldStatus, error) {
+
+	// request the legal hold status of the object version
+
+	out, err := client.GetObjectLegalHold(ctx, &s3.GetObjectLegalHoldInput{
+		Bucket:    &bucket,
+		Key:       &key,
+		VersionId: optionalString(versionID),
+	})
+	if err != nil {
+		return "", err
+	}
+	if out.LegalHold == nil {
+		return types.ObjectLockLegalHoldStatusOff, nil
+	}
+	return out.LegalHold.Status, nil
+
+}
+
+func verifyObjectLock(lockMode types.ObjectLockMode, lockUntil *time.Time, mode types.ObjectLockMode, retainUntil time.Time) error {
+
+	// compare the Object Lock of the object with the requested one - S3 stores the date with a precision of seconds
+
+	if lockMode != mode {
+		return fmt.Errorf("object lock mode is %q, expected %q", lockMode, mode)
+	}
+	if lockUntil == nil {
+		return fmt.Errorf("object has no retain until date, expected %s", retainUntil.Format(time.RFC3339))
+	}
+	diff := lockUntil.Sub(retainUntil)
+	if diff < -time.Second || diff > time.Second {
+		return fmt.Errorf("object retain until date is %s, expected %s",
+			lockUntil.UTC().Format(time.RFC3339), retainUntil.Format(time.RFC3339))
+	}
+	return nil
+
+}
+
+func etagMatchesMD5(etag string, md5h string) (bool, error) {
+
+	// the ETag of a single part upload is the hex md5hash in quotes, the md5hash of the Content-MD5 header is base64
+
+	sum, err := base64.StdEncoding.DecodeString(md5h)
+	if err != nil {
+		return false, err
+	}
+	return strings.EqualFold(strings.Trim(etag, `"`), hex.EncodeToString(sum)), nil
+
+}
+
+// maxClockSkew is the tolerated difference between the local clock and the clock of the endpoint
+const maxClockSkew = time.Minute
+
+func clockSkew(metadata middleware.Metadata, now time.Time) (time.Duration, bool) {
+
+	// the retain until date is calculated with the local clock, but enforced with the clock of the endpoint
+	// the Date header of the response tells the time of the endpoint with a precision of seconds
+
+	raw, ok := awsmiddleware.GetRawResponse(metadata).(*smithyhttp.Response)
+	if !ok {
+		return 0, false
+	}
+	date, err := http.ParseTime(raw.Header.Get("Date"))
+	if err != nil {
+		return 0, false
+	}
+	return date.Sub(now).Round(time.Second), true
+
+}
+
+func getObjectMD5Hash(ctx context.Context, client S3API, bucket string, key string, versionID string) (string, error) {
+
+	// download the object version and calculate its md5hash while streaming the body
+
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket:    &bucket,
+		Key:       &key,
+		VersionId: optionalString(versionID),
+	})
+	if err != nil {
+		return "", err
+	}
+	defer out.Body.Close()
+
+	hasher := md5.New()
+	_, err = io.Copy(hasher, out.Body)
+	if err != nil {
+		return "", err
+	}
+
+	// base64 encoded like the Content-MD5 of the upload
+	return base64.StdEncoding.EncodeToString(hasher.Sum(nil)), nil
+
+}
+
+func deleteObjectVersion(ctx context.Context, client S3API, bucket string, key string, versionID string, bypassGovernance bool) error {
+
+	// only the delete of a specific version is protected by Object Lock - without a version id
+	// S3 just adds a delete marker to the versioned bucket
+
+	_, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:                    &bucket,
+		Key:                       &key,
+		VersionId:                 optionalString(versionID),
+		BypassGovernanceRetention: bypassGovernance,
+	})
+	return err
+
+}
+
+// ErrObjectLockUnsupported marks an endpoint without Object Lock, e.g. an S3 compatible storage
+var ErrObjectLockUnsupported = errors.New("this endpoint does not support Object Lock")
+
+// RetentionShortenedError refuses a retention date earlier than the current one - the wrapper only extends a retention,
+// even a GOVERNANCE retention that S3 would shorten with s3:BypassGovernanceRetention
+type RetentionShortenedError struct {
+	Key       string
+	Current   time.Time
+	Requested time.Time
+}
+
+func (e *RetentionShortenedError) Error() string {
+
+	return fmt.Sprintf("the retention date %s of %s is earlier than the current retention date %s - a retention can only be extended",
+		e.Requested.UTC().Format(time.RFC3339), e.Key, e.Current.UTC().Format(time.RFC3339))
+
+}
+
+func isObjectLockUnsupported(err error) bool {
+
+	// S3 compatible storages without Object Lock reject the lock parameters of the bucket with NotImplemented,
+	// some with InvalidRequest - which is also the error of any other invalid parameter, so it needs the Object Lock in its message
+
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "NotImplemented":
+		return true
+	case "InvalidRequest":
+		message := strings.ToLower(apiErr.ErrorMessage())
+		return strings.Contains(message, "object lock") || strings.Contains(message, "objectlock")
+	}
+	return false
+
+}
+
+func digestError(err error) string {
+
+	// classify the rejection of the Content-MD5 header: BadDigest means the content differs from the md5hash,
+	// InvalidDigest or NotImplemented mean the endpoint does not accept the header at all
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "BadDigest", "InvalidDigest", "NotImplemented":
+			return apiErr.ErrorCode()
+		}
+	}
+	return ""
+
+}
+
+func isOperationAborted(err error) bool {
+
+	// a conflicting operation on the bucket is in progress, e.g. the deletion of a bucket with the same name
+
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "OperationAborted"
+
+}
+
+func isLockTokenError(err error) bool {
+
+	// S3 refuses to enable Object Lock on a bucket created without it - unless a valid token is supplied
+
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "InvalidBucketState", "InvalidToken":
+		return true
+	}
+	return false
+
+}
+
+func isWrongRegion(err error) bool {
+
+	// S3 redirects the requests for a bucket of another region, or rejects their signature with the wrong region
+
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && (apiErr.ErrorCode() == "PermanentRedirect" || apiErr.ErrorCode() == "AuthorizationHeaderMalformed")
+
+}
+
+func isNoSuchBucket(err error) bool {
+
+	// the bucket does not exist (yet)
+
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchBucket"
+
+}
+
+func isObjectLockProtection(err error) bool {
+
+	// S3 rejects the delete of a locked object version with AccessDenied "because object protected by object lock",
+	// MinIO with InvalidRequest "Object is WORM protected" - any other AccessDenied is a denial of IAM or of a bucket policy
+
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "AccessDenied" && apiErr.ErrorCode() != "InvalidRequest" {
+		return false
+	}
+	message := strings.ToLower(apiErr.ErrorMessage())
+	return strings.Contains(message, "object lock") || strings.Contains(message, "worm protected")
+
+}
+
+func isAccessDenied(err error) bool {
+
+	// S3 rejects requests against locked objects with AccessDenied
+
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDenied"
+
+}
+
+// IsThrottled reports whether S3 throttled the request, e.g. after the last retry of a call
+func IsThrottled(err error) bool {
+
+	// S3 throttles with SlowDown and 503 Service Unavailable, S3 compatible storages use the other codes as well
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "SlowDown", "ServiceUnavailable", "Throttling", "ThrottlingException", "RequestLimitExceeded":
+			return true
+		}
+	}
+	var re *awshttp.ResponseError
+	return errors.As(err, &re) && re.HTTPStatusCode() == http.StatusServiceUnavailable
+
+}
+
+func isACLNotSupported(err error) bool {
+
+	// a bucket with the Object Ownership BucketOwnerEnforced rejects each request with an ACL
+
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessControlListNotSupported"
+
+}
+
+func optionalString(s string) *string {
+
+	// an empty string is omitted from the request, e.g. the version id of an unversioned object
+
+	if s == "" {
+		return nil
+	}
+	return &s
+
+}
+
+func checkRetentionAgainstDefault(retainUntil time.Time, retention *types.DefaultRetention, now time.Time) error {
+
+	// an object retention shorter than the default retention of the bucket under-protects the object
+
+	if retention == nil {
+		return nil
+	}
+	defaultUntil := now.AddDate(int(retention.Years), 0, int(retention.Days))
+	if retainUntil.Before(defaultUntil) {
+		return fmt.Errorf("the retention date %s of the object is earlier than the default retention of the bucket until %s",
+			retainUntil.Format(time.RFC3339), defaultUntil.Format(time.RFC3339))
+	}
+	return nil
+
+}
@@ -0,0 +1,339 @@
+package objectlock
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// fakeS3 serves a single bucket with a single object version and records the calls that change them,
+// the other methods of the embedded nil interface panic if a test reaches them
+type fakeS3 struct {
+	S3API
+	bucketExists      bool
+	kmsDecryptDenied  bool
+	lockConfiguration types.ObjectLockConfiguration
+	retention         types.ObjectLockRetention
+	createBucketArgs  []*s3.CreateBucketInput
+	putLockArgs       []*s3.PutObjectLockConfigurationInput
+	putRetentionArgs  []*s3.PutObjectRetentionInput
+	headObjectArgs    []*s3.HeadObjectInput
+	deleteBucketArgs  []*s3.DeleteBucketInput
+	// the errors of the next CreateBucket calls, e.g. OperationAborted after a deletion
+	createBucketErrs []error
+	deleteObjectErr  error
+	deleteObjectArgs []*s3.DeleteObjectInput
+	putObjectArgs    []*s3.PutObjectInput
+	putObjectBodies  [][]byte
+	replication      *types.ReplicationConfiguration
+	attributesArgs   []*s3.GetObjectAttributesInput
+}
+
+func (f *fakeS3) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+
+	f.deleteObjectArgs = append(f.deleteObjectArgs, params)
+	if f.deleteObjectErr != nil {
+		return nil, f.deleteObjectErr
+	}
+	return &s3.DeleteObjectOutput{}, nil
+
+}
+
+func (f *fakeS3) HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+
+	if !f.bucketExists {
+		return nil, &types.NotFound{}
+	}
+	return &s3.HeadBucketOutput{}, nil
+
+}
+
+func (f *fakeS3) CreateBucket(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+
+	// like us-east-1, an owned bucket is created again without an error
+	f.createBucketArgs = append(f.createBucketArgs, params)
+	if len(f.createBucketErrs) > 0 {
+		err := f.createBucketErrs[0]
+		f.createBucketErrs = f.createBucketErrs[1:]
+		return nil, err
+	}
+	f.bucketExists = true
+	return &s3.CreateBucketOutput{}, nil
+
+}
+
+func (f *fakeS3) DeleteBucket(ctx context.Context, params *s3.DeleteBucketInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketOutput, error) {
+
+	f.deleteBucketArgs = append(f.deleteBucketArgs, params)
+	f.bucketExists = false
+	return &s3.DeleteBucketOutput{}, nil
+
+}
+
+func (f *fakeS3) ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+
+	// the bucket has no object versions
+	if !f.bucketExists {
+		return nil, &types.NoSuchBucket{}
+	}
+	return &s3.ListObjectVersionsOutput{}, nil
+
+}
+
+func (f *fakeS3) GetObjectLockConfiguration(ctx context.Context, params *s3.GetObjectLockConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetObjectLockConfigurationOutput, error) {
+
+	lockConfiguration := f.lockConfiguration
+	return &s3.GetObjectLockConfigurationOutput{ObjectLockConfiguration: &lockConfiguration}, nil
+
+}
+
+func (f *fakeS3) PutObjectLockConfiguration(ctx context.Context, params *s3.PutObjectLockConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutObjectLockConfigurationOutput, error) {
+
+	f.putLockArgs = append(f.putLockArgs, params)
+	return &s3.PutObjectLockConfigurationOutput{}, nil
+
+}
+
+func (f *fakeS3) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+
+	// the checksum of an SSE-KMS object needs kms:Decrypt, like its content
+	f.headObjectArgs = append(f.headObjectArgs, params)
+	if f.kmsDecryptDenied && params.ChecksumMode == types.ChecksumModeEnabled {
+		return nil, &smithy.GenericAPIError{Code: "AccessDenied", Message: "Access Denied"}
+	}
+	return &s3.HeadObjectOutput{
+		VersionId:                 aws.String("v1"),
+		ObjectLockMode:            types.ObjectLockMode(f.retention.Mode),
+		ObjectLockRetainUntilDate: f.retention.RetainUntilDate,
+	}, nil
+
+}
+
+func (f *fakeS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+
+	// the uploaded version carries the retention of the request
+	f.putObjectArgs = append(f.putObjectArgs, params)
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.putObjectBodies = append(f.putObjectBodies, body)
+	f.retention = types.ObjectLockRetention{Mode: types.ObjectLockRetentionMode(params.ObjectLockMode), RetainUntilDate: params.ObjectLockRetainUntilDate}
+	return &s3.PutObjectOutput{VersionId: aws.String("v1")}, nil
+
+}
+
+func (f *fakeS3) GetObjectAttributes(ctx context.Context, params *s3.GetObjectAttributesInput, optFns ...func(*s3.Options)) (*s3.GetObjectAttributesOutput, error) {
+
+	f.attributesArgs = append(f.attributesArgs, params)
+	return &s3.GetObjectAttributesOutput{VersionId: aws.String("v1"), StorageClass: types.StorageClassStandard}, nil
+
+}
+
+func (f *fakeS3) GetBucketReplication(ctx context.Context, params *s3.GetBucketReplicationInput, optFns ...func(*s3.Options)) (*s3.GetBucketReplicationOutput, error) {
+
+	return &s3.GetBucketReplicationOutput{ReplicationConfiguration: f.replication}, nil
+
+}
+
+func (f *fakeS3) GetObjectLegalHold(ctx context.Context, params *s3.GetObjectLegalHoldInput, optFns ...func(*s3.Options)) (*s3.GetObjectLegalHoldOutput, error) {
+
+	return &s3.GetObjectLegalHoldOutput{LegalHold: &types.ObjectLockLegalHold{Status: types.ObjectLockLegalHoldStatusOff}}, nil
+
+}
+
+func (f *fakeS3) GetObjectRetention(ctx context.Context, params *s3.GetObjectRetentionInput, optFns ...func(*s3.Options)) (*s3.GetObjectRetentionOutput, error) {
+
+	retention := f.retention
+	return &s3.GetObjectRetentionOutput{Retention: &retention}, nil
+
+}
+
+func (f *fakeS3) PutObjectRetention(ctx context.Context, params *s3.PutObjectRetentionInput, optFns ...func(*s3.Options)) (*s3.PutObjectRetentionOutput, error) {
+
+	f.putRetentionArgs = append(f.putRetentionArgs, params)
+	return &s3.PutObjectRetentionOutput{}, nil
+
+}
+
+func TestExtendRetention(t *testing.T) {
+
+	// an earlier date is refused without a PutObjectRetention, the same or a later date is put with the current mode
+
+	current := time.Date(2030, 1, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		mode      types.ObjectLockRetentionMode
+		until     time.Time
+		shortened bool
+	}{
+		{name: "lesser compliance", mode: types.ObjectLockRetentionModeCompliance, until: current.Add(-time.Second), shortened: true},
+		{name: "lesser governance", mode: types.ObjectLockRetentionModeGovernance, until: current.AddDate(0, 0, -1), shortened: true},
+		{name: "equal", mode: types.ObjectLockRetentionModeCompliance, until: current},
+		{name: "greater compliance", mode: types.ObjectLockRetentionModeCompliance, until: current.AddDate(1, 0, 0)},
+		{name: "greater governance", mode: types.ObjectLockRetentionModeGovernance, until: current.Add(time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeS3{retention: types.ObjectLockRetention{Mode: tt.mode, RetainUntilDate: aws.Time(current)}}
+			_, err := extendRetention(context.Background(), client, "test-wormbucket", "iris.csv", "v1", tt.until)
+
+			if tt.shortened {
+				var shortened *RetentionShortenedError
+				if !errors.As(err, &shortened) {
+					t.Fatalf("extendRetention() error = %v, want a *RetentionShortenedError", err)
+				}
+				if !shortened.Current.Equal(current) || !shortened.Requested.Equal(tt.until) {
+					t.Errorf("RetentionShortenedError = %+v, want Current %s and Requested %s", shortened, current, tt.until)
+				}
+				// the flags belong to the command, not to the error of the library
+				if strings.Contains(err.Error(), "[-") {
+					t.Errorf("RetentionShortenedError = %q, want no flag hint", err)
+				}
+				if len(client.putRetentionArgs) != 0 {
+					t.Errorf("extendRetention() made %d PutObjectRetention calls, want 0", len(client.putRetentionArgs))
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("extendRetention() error = %v", err)
+			}
+			if len(client.putRetentionArgs) != 1 {
+				t.Fatalf("extendRetention() made %d PutObjectRetention calls, want 1", len(client.putRetentionArgs))
+			}
+			put := client.putRetentionArgs[0]
+			if put.Retention.Mode != tt.mode {
+				t.Errorf("PutObjectRetention mode = %s, want the unchanged %s", put.Retention.Mode, tt.mode)
+			}
+			if !aws.ToTime(put.Retention.RetainUntilDate).Equal(tt.until) {
+				t.Errorf("PutObjectRetention date = %s, want %s", aws.ToTime(put.Retention.RetainUntilDate), tt.until)
+			}
+			if aws.ToString(put.VersionId) != "v1" {
+				t.Errorf("PutObjectRetention version = %q, want %q", aws.ToString(put.VersionId), "v1")
+			}
+		})
+	}
+
+}
+
+func TestCreateLockedBucket(t *testing.T) {
+
+	// a bucket of a previous run is detected before the CreateBucket, which succeeds for an owned bucket in us-east-1
+
+	// a recreated bucket is created even if HeadBucket still finds it, a pending deletion is retried
+	defer func(delay time.Duration) { createBucketRetryDelay = delay }(createBucketRetryDelay)
+	createBucketRetryDelay = time.Millisecond
+	aborted := &smithy.GenericAPIError{Code: "OperationAborted"}
+
+	tests := []struct {
+		name        string
+		exists      bool
+		recreated   bool
+		errs        []error
+		wantCreated bool
+		wantCreate  int
+	}{
+		{name: "new bucket", exists: false, wantCreated: true, wantCreate: 1},
+		{name: "bucket of a previous run", exists: true, wantCreated: false, wantCreate: 0},
+		{name: "recreated bucket still found by HeadBucket", exists: true, recreated: true, wantCreated: true, wantCreate: 1},
+		{name: "pending deletion", recreated: true, errs: []error{aborted, aborted}, wantCreated: true, wantCreate: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeS3{bucketExists: tt.exists, createBucketErrs: tt.errs}
+			created, err := createLockedBucket(context.Background(), client, "test-wormbucket", "us-east-1", "", tt.recreated)
+			if err != nil {
+				t.Fatalf("createLockedBucket() error = %v", err)
+			}
+			if created != tt.wantCreated {
+				t.Errorf("createLockedBucket() created = %t, want %t", created, tt.wantCreated)
+			}
+			if len(client.createBucketArgs) != tt.wantCreate {
+				t.Errorf("createLockedBucket() made %d CreateBucket calls, want %d", len(client.createBucketArgs), tt.wantCreate)
+			}
+		})
+	}
+
+}
+
+func TestIsObjectLockUnsupported(t *testing.T) {
+
+	// only an InvalidRequest about the Object Lock tells an endpoint without it, not an invalid parameter of another kind
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "not implemented", err: &smithy.GenericAPIError{Code: "NotImplemented", Message: "A header you provided implies functionality that is not implemented"}, want: true},
+		{name: "object lock rejected", err: &smithy.GenericAPIError{Code: "InvalidRequest", Message: "Object Lock is not supported for this bucket"}, want: true},
+		{name: "object lock configuration rejected", err: &smithy.GenericAPIError{Code: "InvalidRequest", Message: "ObjectLockConfiguration is not supported"}, want: true},
+		{name: "unrelated invalid request", err: &smithy.GenericAPIError{Code: "InvalidRequest", Message: "Missing required header for this request: x-amz-content-sha256"}, want: false},
+		{name: "access denied", err: &smithy.GenericAPIError{Code: "AccessDenied", Message: "Access Denied"}, want: false},
+		{name: "no API error", err: errors.New("connection refused"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isObjectLockUnsupported(tt.err); got != tt.want {
+				t.Errorf("isObjectLockUnsupported(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+
+}
+
+func TestRecreateBucket(t *testing.T) {
+
+	// an empty bucket is deleted and gone before the recreation, a missing bucket is no failure
+
+	client := &fakeS3{bucketExists: true}
+	deleted, err := recreateBucket(context.Background(), client, "test-wormbucket", time.Now())
+	if err != nil {
+		t.Fatalf("recreateBucket() error = %v", err)
+	}
+	if !deleted || len(client.deleteBucketArgs) != 1 {
+		t.Errorf("recreateBucket() deleted = %t with %d DeleteBucket calls, want true with 1", deleted, len(client.deleteBucketArgs))
+	}
+
+	deleted, err = recreateBucket(context.Background(), client, "test-wormbucket", time.Now())
+	if err != nil || deleted {
+		t.Errorf("recreateBucket() of a missing bucket = %t, %v, want false, nil", deleted, err)
+	}
+
+}
+
+func TestCheckReplication(t *testing.T) {
+
+	// a response without a replication configuration is no replication, the rules are checked against their destination
+
+	rules, err := checkReplication(context.Background(), &fakeS3{}, "test-wormbucket")
+	if err != nil || rules != nil {
+		t.Errorf("checkReplication() without a configuration = %v, %v, want no rules", rules, err)
+	}
+
+	client := &fakeS3{
+		lockConfiguration: types.ObjectLockConfiguration{ObjectLockEnabled: types.ObjectLockEnabledEnabled},
+		replication: &types.ReplicationConfiguration{Rules: []types.ReplicationRule{{
+			ID:          aws.String("worm"),
+			Status:      types.ReplicationRuleStatusEnabled,
+			Destination: &types.Destination{Bucket: aws.String("arn:aws:s3:::test-wormbucket-replica")},
+		}}},
+	}
+	rules, err = checkReplication(context.Background(), client, "test-wormbucket")
+	if err != nil {
+		t.Fatalf("checkReplication() error = %v", err)
+	}
+	if len(rules) != 1 || rules[0].DestinationBucket != "test-wormbucket-replica" || !rules[0].PreservesObjectLock {
+		t.Errorf("checkReplication() = %+v, want one rule into test-wormbucket-replica that preserves the Object Lock", rules)
+	}
+
+}
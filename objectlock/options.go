@@ -0,0 +1,639 @@
+package objectlock
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Config bundles the input arguments of the Object Lock workflow, each subcommand parses only its own flags
+type Config struct {
+	// the client - an empty Region keeps the region of the AWS configuration,
+	// callers of Run limit the duration with their context
+	Region  string
+	Profile string
+	// an assumed role of another account - empty keeps the credentials of the default chain
+	RoleARN         string
+	RoleSessionName string
+	Endpoint        string
+	RegionAuto      bool
+	MaxAttempts     int
+	Verbose         bool
+	DebugHTTP       bool
+	Metrics         bool
+	// ResolveAccount looks up the AWS account of the credentials with STS into the Result, e.g. for an audit trail
+	ResolveAccount bool
+	// the tables and the lock banners of the run - nil discards them, the messages are logged with slog
+	Output io.Writer
+	Color  bool
+
+	// the bucket and its default retention - Mode is governance or compliance
+	Bucket             string
+	Mode               string
+	RetentionDays      int
+	RetentionYears     int
+	NoDefaultRetention bool
+	LockToken          string
+	SkipCreate         bool
+	ForceRecreate      bool
+	StrictRetention    bool
+
+	// the objects - Filename is a file or a directory, RetainUntil an RFC3339 date
+	Filename           string
+	URL                string
+	Key                string
+	KeyPrefix          string
+	VersionID          string
+	ObjectMode         string
+	ContentType        string
+	ContentDisposition string
+	CacheControl       string
+	Expires            string
+	ACL                string
+	StorageClass       string
+	Tags               string
+	Metadata           map[string]string
+	SSE                string
+	KMSKeyID           string
+	BucketKey          bool
+	RetainUntil        string
+	Retain             string
+	ExtendUntil        string
+	Checksum           string
+	NoMD5              bool
+	Integrity          string
+	MultipartThreshold int64
+	PartSize           int64
+	UploadConcurrency  int
+	Concurrency        int
+	Versions           int
+	LegalHold          bool
+	LegalHoldStatus    string
+	Prefix             string
+	FilterMode         string
+	VerifyDownload     bool
+	VerifyAPI          string
+	Delete             bool
+	BypassGovernance   bool
+	Cleanup            bool
+	HeadOnly           bool
+	PutOnly            bool
+	DryRun             bool
+	WaitExpiry         bool
+}
+
+// DefaultConfig returns a Config with the defaults of the flags of the demo
+func DefaultConfig() Config {
+
+	// registering the flags sets their defaults, so the defaults are only defined once
+
+	var cfg Config
+	fs := flag.NewFlagSet("defaults", flag.ContinueOnError)
+	cfg.ClientFlags(fs)
+	cfg.bucketFlags(fs)
+	cfg.retentionFlags(fs)
+	cfg.uploadFlags(fs)
+	cfg.aclFlags(fs)
+	cfg.verifyFlags(fs)
+	return cfg
+
+}
+
+// metadataFlag collects the repeated -meta key=value flags, S3 stores them as x-amz-meta-* headers
+type metadataFlag map[string]string
+
+func (m *metadataFlag) String() string {
+
+	return fmt.Sprint(map[string]string(*m))
+
+}
+
+func (m *metadataFlag) Set(value string) error {
+
+	// each -meta flag adds one entry
+
+	k, v, ok := strings.Cut(value, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("invalid metadata %q, expected key=value [-meta key=value]", value)
+	}
+	if *m == nil {
+		*m = metadataFlag{}
+	}
+	// S3 returns the keys in lower case, so compare them the same way
+	(*m)[strings.ToLower(k)] = v
+	return nil
+
+}
+
+// ClientFlags registers the flags of the AWS configuration and its debug output, shared by all subcommands
+func (cfg *Config) ClientFlags(fs *flag.FlagSet) {
+
+	fs.StringVar(&cfg.Region, "r", "us-east-1", "AWS region")
+	fs.StringVar(&cfg.Profile, "profile", "", "The AWS profile of the shared configuration and credentials files")
+	fs.StringVar(&cfg.RoleARN, "role-arn", "", "The ARN of an IAM role to assume, e.g. for the bucket of another account")
+	fs.StringVar(&cfg.RoleSessionName, "role-session-name", "", "The session name of the assumed role [-role-arn], by default a generated name")
+	fs.StringVar(&cfg.Endpoint, "endpoint", "", "A custom S3 endpoint URL, e.g. for MinIO or Ceph")
+	fs.BoolVar(&cfg.RegionAuto, "region-auto", false, "Detect the region of an existing bucket with GetBucketLocation after a PermanentRedirect and use it instead of [-r]")
+	fs.IntVar(&cfg.MaxAttempts, "max-attempts", 3, "The maximum number of attempts of each S3 API call with adaptive retries")
+	fs.BoolVar(&cfg.Verbose, "verbose", false, "Log each API call with its input and latency")
+	fs.BoolVar(&cfg.DebugHTTP, "debug-http", false, "Log the headers of each HTTP request and response, e.g. x-amz-object-lock-mode")
+	fs.BoolVar(&cfg.Metrics, "metrics", false, "Print the duration of each API call, with -json in the calls of the result")
+
+}
+
+func (cfg *Config) output() io.Writer {
+
+	// a caller of Run without an output only gets the Result and the log messages
+
+	if cfg.Output == nil {
+		return io.Discard
+	}
+	return cfg.Output
+
+}
+
+func (cfg *Config) banner() banner {
+
+	return banner{w: cfg.output(), color: cfg.Color}
+
+}
+
+func (cfg *Config) bucketFlags(fs *flag.FlagSet) {
+
+	fs.StringVar(&cfg.Bucket, "b", "", "The name of the bucket")
+
+}
+
+func (cfg *Config) retentionFlags(fs *flag.FlagSet) {
+
+	// the flags of the default retention of the bucket
+
+	fs.StringVar(&cfg.Mode, "mode", "governance", "The default retention mode of the bucket: governance or compliance")
+	fs.IntVar(&cfg.RetentionDays, "retention-days", 0, "The default retention period of the bucket in days (default 2 if no years are set)")
+	fs.IntVar(&cfg.RetentionYears, "retention-years", 0, "The default retention period of the bucket in years")
+	fs.StringVar(&cfg.LockToken, "lock-token", "", "The token to enable Object Lock on an existing bucket that was created without it")
+	fs.BoolVar(&cfg.NoDefaultRetention, "no-default-retention", false, "Enable Object Lock on the bucket without a default retention - only the objects carry a retention")
+
+}
+
+func (cfg *Config) uploadFlags(fs *flag.FlagSet) {
+
+	// the flags of the locked upload
+
+	fs.StringVar(&cfg.Filename, "f", "", "The file to upload, a directory to upload each of its files, or - to upload stdin")
+	fs.StringVar(&cfg.URL, "url", "", "An HTTP or HTTPS URL to download and upload instead of a file [-f]")
+	fs.StringVar(&cfg.Key, "key", "", "The key of the object in the bucket (default the base name of the file)")
+	fs.StringVar(&cfg.KeyPrefix, "prefix", "", "A prefix of the keys of the uploaded objects, e.g. 2024/legal/ for a shared bucket")
+	fs.StringVar(&cfg.ContentType, "content-type", "", "The content type of the uploaded objects (default detected from the first bytes of each file)")
+	fs.StringVar(&cfg.ContentDisposition, "content-disposition", "", "The Content-Disposition of the uploaded objects, e.g. attachment; filename=\"report.pdf\"")
+	fs.StringVar(&cfg.CacheControl, "cache-control", "", "The Cache-Control of the uploaded objects, e.g. max-age=3600")
+	fs.StringVar(&cfg.StorageClass, "storage-class", "", "The storage class of the uploaded objects, e.g. STANDARD_IA, GLACIER_IR or DEEP_ARCHIVE")
+	fs.StringVar(&cfg.Expires, "expires", "", "The HTTP Expires header of the uploaded objects in RFC3339 format - no Object Lock retention")
+	fs.StringVar(&cfg.Tags, "tags", "", "The tags of the uploaded objects, e.g. retention-class=legal,department=hr")
+	fs.Var((*metadataFlag)(&cfg.Metadata), "meta", "A metadata entry key=value of the uploaded objects, repeat the flag for more entries")
+	fs.StringVar(&cfg.SSE, "sse", "", "The server-side encryption of the uploaded objects: AES256 or aws:kms (default the encryption of the bucket)")
+	fs.StringVar(&cfg.KMSKeyID, "kms-key-id", "", "The KMS key for the server-side encryption aws:kms (default the AWS managed key)")
+	fs.BoolVar(&cfg.BucketKey, "bucket-key", false, "Use an S3 Bucket Key for the server-side encryption aws:kms to reduce the KMS requests")
+	fs.StringVar(&cfg.ObjectMode, "object-mode", "compliance", "The retention mode of the uploaded object: governance or compliance")
+	fs.StringVar(&cfg.Retain, "retain", "", "The retention period of the object from the upload on, e.g. 72h or 30d")
+	fs.StringVar(&cfg.RetainUntil, "retain-until", "", "The retention date of the object in RFC3339 format, e.g. 2030-01-31T00:00:00Z (default 1 day from now)")
+	fs.StringVar(&cfg.Checksum, "checksum", "md5", "The integrity check of the upload: md5, sha256, crc32 or crc32c")
+	fs.BoolVar(&cfg.NoMD5, "no-md5", false, "Omit the Content-MD5 header for S3 compatible storages that reject it")
+	fs.StringVar(&cfg.Integrity, "integrity", "", "The integrity strategy of the upload instead of [-checksum] and [-no-md5]: md5, crc32c or none (default md5)")
+	fs.Int64Var(&cfg.MultipartThreshold, "multipart-threshold", 100*1024*1024, "Files from this size in bytes on are uploaded in parts, 0 disables multipart uploads")
+	fs.Int64Var(&cfg.PartSize, "part-size", 8*1024*1024, "The size in bytes of the parts of a multipart upload, at least 5 MiB")
+	fs.IntVar(&cfg.UploadConcurrency, "upload-concurrency", manager.DefaultUploadConcurrency, "The number of parallel part uploads of a multipart upload")
+	fs.IntVar(&cfg.Concurrency, "concurrency", 4, "The number of parallel uploads for the files of a directory")
+	fs.IntVar(&cfg.Versions, "versions", 1, "The number of versions of the object to upload with the same key, each with its own Object Lock")
+	fs.BoolVar(&cfg.LegalHold, "legal-hold", false, "Put a legal hold on the uploaded object")
+	fs.BoolVar(&cfg.VerifyDownload, "verify-download", false, "Download the object after the upload and compare its md5hash")
+	fs.BoolVar(&cfg.WaitExpiry, "wait-expiry", false, "Wait for the end of a short retention [-retain 10s] and verify that the object version can be deleted then")
+	fs.BoolVar(&cfg.StrictRetention, "strict-retention", false, "Fail instead of warn if the object retention is shorter than the default retention of the bucket")
+	fs.BoolVar(&cfg.DryRun, "dry-run", false, "Only log the intended API calls without sending any request to AWS")
+
+}
+
+func (cfg *Config) verifyFlags(fs *flag.FlagSet) {
+
+	// the API of the verification of an object
+
+	fs.StringVar(&cfg.VerifyAPI, "verify-api", verifyHead, "The API of the object verification: head (HeadObject) or attributes (GetObjectAttributes with the parts of a multipart upload)")
+
+}
+
+func (cfg *Config) aclFlags(fs *flag.FlagSet) {
+
+	// the canned ACL of the commands that create a bucket or objects
+
+	fs.StringVar(&cfg.ACL, "acl", "", "A canned ACL of the bucket and the objects, e.g. private or bucket-owner-full-control - empty keeps them private")
+
+}
+
+func (cfg *Config) objectFlags(fs *flag.FlagSet) {
+
+	// the flags of an existing object version
+
+	fs.StringVar(&cfg.Key, "key", "", "The key of the object in the bucket")
+	fs.StringVar(&cfg.VersionID, "version-id", "", "The version of the object (default the latest version)")
+
+}
+
+func (cfg *Config) bucketName(res *Result) error {
+
+	// a new bucket without a name gets a generated one - print it, so the bucket can be cleaned up later
+	// a supplied name of a new bucket must follow the naming rules of S3, an existing bucket is used as it is
+
+	if cfg.Bucket != "" {
+		if cfg.SkipCreate {
+			return nil
+		}
+		return validateBucketName(cfg.Bucket)
+	}
+	name, err := generateBucketName(time.Now())
+	if err != nil {
+		return fmt.Errorf("generate bucket name: %w", err)
+	}
+	cfg.Bucket = name
+	res.Bucket = name
+	slog.Info("generated bucket name", "bucket", name)
+	return nil
+
+}
+
+func (cfg *Config) defaultRetention() (*types.DefaultRetention, error) {
+
+	// check the default retention before any request is sent to AWS - nil means no default retention
+
+	if cfg.NoDefaultRetention {
+		if cfg.RetentionDays != 0 || cfg.RetentionYears != 0 {
+			return nil, errors.New("a retention period cannot be combined with [-no-default-retention]")
+		}
+		return nil, nil
+	}
+	retentionMode, err := parseRetentionMode(cfg.Mode)
+	if err != nil {
+		return nil, err
+	}
+	return newDefaultRetention(retentionMode, cfg.RetentionDays, cfg.RetentionYears)
+
+}
+
+func (cfg *Config) lockSettings() (lockSettings, error) {
+
+	// check the retention of the object - S3 rejects dates in the past
+
+	objectRetentionMode, err := parseRetentionMode(cfg.ObjectMode)
+	if err != nil {
+		return lockSettings{}, err
+	}
+	now := time.Now().UTC()
+	rt, err := parseRetainUntil(cfg.RetainUntil, now)
+	if err != nil {
+		return lockSettings{}, err
+	}
+	// a retention period relative to the upload instead of a fixed date
+	if cfg.Retain != "" {
+		if cfg.RetainUntil != "" {
+			return lockSettings{}, errors.New("a retention period [-retain] cannot be combined with a retention date [-retain-until]")
+		}
+		d, err := parseRetainDuration(cfg.Retain)
+		if err != nil {
+			return lockSettings{}, err
+		}
+		rt = now.Add(d)
+	}
+
+	// a legal hold protects the object version beyond its retention
+	if cfg.WaitExpiry && cfg.LegalHold {
+		return lockSettings{}, errors.New("an object with a legal hold [-legal-hold] cannot be deleted after its retention [-wait-expiry]")
+	}
+
+	// check the parallel uploads of a directory
+	if cfg.Concurrency < 1 {
+		return lockSettings{}, errors.New("the concurrency must be at least 1 [-concurrency WORKERS]")
+	}
+	if cfg.Versions < 1 {
+		return lockSettings{}, errors.New("the number of versions must be at least 1 [-versions N]")
+	}
+
+	// check the tuning of the multipart uploads against the minimum part size of S3
+	if cfg.PartSize < manager.MinUploadPartSize {
+		return lockSettings{}, fmt.Errorf("the part size %d is smaller than the S3 minimum of %d bytes [-part-size BYTES]", cfg.PartSize, manager.MinUploadPartSize)
+	}
+	if cfg.UploadConcurrency < 1 {
+		return lockSettings{}, errors.New("the upload concurrency must be at least 1 [-upload-concurrency PARTS]")
+	}
+
+	// check the API of the integrity verification
+	verifyAPI, err := parseVerifyAPI(cfg.VerifyAPI)
+	if err != nil {
+		return lockSettings{}, err
+	}
+
+	// check the checksum algorithm of the upload
+	checksumAlgorithm, err := parseChecksumAlgorithm(cfg.Checksum)
+	if err != nil {
+		return lockSettings{}, err
+	}
+	// the integrity strategy replaces the checksum and the Content-MD5 header in one flag
+	noMD5 := cfg.NoMD5
+	if cfg.Integrity != "" {
+		if checksumAlgorithm != "" || cfg.NoMD5 {
+			return lockSettings{}, errors.New("the integrity strategy [-integrity] cannot be combined with [-checksum] or [-no-md5]")
+		}
+		checksumAlgorithm, noMD5, err = parseIntegrity(cfg.Integrity)
+		if err != nil {
+			return lockSettings{}, err
+		}
+	}
+
+	// AWS S3 rejects a locked object without any integrity check
+	if noMD5 && checksumAlgorithm == "" {
+		slog.Warn("the upload has no integrity check without the Content-MD5 header, AWS S3 requires one for Object Lock [-checksum sha256]")
+	}
+
+	// check the encryption at rest of the uploaded objects
+	sse, err := parseServerSideEncryption(cfg.SSE, cfg.KMSKeyID)
+	if err != nil {
+		return lockSettings{}, err
+	}
+	if cfg.BucketKey && sse != types.ServerSideEncryptionAwsKms {
+		slog.Warn("the bucket key [-bucket-key] only applies to the server-side encryption [-sse aws:kms], it is ignored")
+	}
+
+	// the HTTP Expires header only tells caches when to refetch the object, the object is neither deleted nor locked by it
+	var expires *time.Time
+	if cfg.Expires != "" {
+		t, err := time.Parse(time.RFC3339, cfg.Expires)
+		if err != nil {
+			return lockSettings{}, fmt.Errorf("invalid expires date %q [-expires 2030-01-31T00:00:00Z]", cfg.Expires)
+		}
+		expires = &t
+	}
+
+	// the archive classes keep the Object Lock, but an object must be restored before it can be read
+	storageClass, err := parseStorageClass(cfg.StorageClass)
+	if err != nil {
+		return lockSettings{}, err
+	}
+	if storageClass == types.StorageClassGlacier || storageClass == types.StorageClassDeepArchive {
+		if cfg.VerifyDownload {
+			return lockSettings{}, fmt.Errorf("an object of the storage class %s cannot be downloaded without a restore [-verify-download]", storageClass)
+		}
+		slog.Warn("an object of the storage class must be restored before it can be read, the restore takes hours", "StorageClass", storageClass)
+	}
+
+	// check the canned ACL of the objects
+	acl, _, err := parseACL(cfg.ACL)
+	if err != nil {
+		return lockSettings{}, err
+	}
+
+	// check the tags against the limits of S3
+	tagging, err := parseTags(cfg.Tags)
+	if err != nil {
+		return lockSettings{}, err
+	}
+
+	return lockSettings{
+		ContentType:        cfg.ContentType,
+		ContentDisposition: cfg.ContentDisposition,
+		CacheControl:       cfg.CacheControl,
+		Expires:            expires,
+		ACL:                acl,
+		StorageClass:       storageClass,
+		Tagging:            tagging,
+		Metadata:           cfg.Metadata,
+		SSE:                sse,
+		KMSKeyID:           cfg.KMSKeyID,
+		BucketKey:          cfg.BucketKey && sse == types.ServerSideEncryptionAwsKms,
+		Mode:               types.ObjectLockMode(objectRetentionMode),
+		RetainUntil:        rt,
+		ChecksumAlgorithm:  checksumAlgorithm,
+		NoMD5:              noMD5,
+		MultipartThreshold: cfg.MultipartThreshold,
+		PartSize:           cfg.PartSize,
+		UploadConcurrency:  cfg.UploadConcurrency,
+		LegalHold:          cfg.LegalHold,
+		VerifyDownload:     cfg.VerifyDownload,
+		Delete:             cfg.Delete,
+		BypassGovernance:   cfg.BypassGovernance,
+		WaitExpiry:         cfg.WaitExpiry,
+		KeyPrefix:          cfg.KeyPrefix,
+		VerifyAPI:          verifyAPI,
+		Banner:             cfg.banner(),
+	}, nil
+
+}
+
+func (cfg *Config) uploadTarget() (isDir bool, err error) {
+
+	// a directory is uploaded with the relative paths of its files as keys, the prefix is added to them per file
+
+	isDir, err = cfg.objectKey()
+	if isDir && err == nil && cfg.Versions > 1 {
+		return true, errors.New("the versions [-versions N] can only be uploaded for a single file")
+	}
+	if err != nil || isDir || cfg.KeyPrefix == "" {
+		return isDir, err
+	}
+	cfg.Key = prefixKey(cfg.KeyPrefix, cfg.Key)
+	slog.Info("the key of the object has the prefix", "prefix", cfg.KeyPrefix, "key", cfg.Key)
+	return false, nil
+
+}
+
+func (cfg *Config) objectKey() (isDir bool, err error) {
+
+	// the key of a single object without the prefix
+
+	// a remote artifact gets the last element of its path as key, unless a key is supplied
+	if cfg.URL != "" {
+		if cfg.Filename != "" {
+			return false, errors.New("a URL [-url] cannot be combined with a file [-f]")
+		}
+		u, err := url.Parse(cfg.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return false, fmt.Errorf("invalid URL %q, expected http:// or https:// [-url URL]", cfg.URL)
+		}
+		if cfg.Key == "" {
+			cfg.Key = path.Base(u.Path)
+			if cfg.Key == "/" || cfg.Key == "." {
+				cfg.Key = "download-" + time.Now().UTC().Format("20060102-150405")
+			}
+		}
+		return false, nil
+	}
+
+	// the content of stdin gets a key with the time of the upload, unless a key is supplied
+	if cfg.Filename == stdinFilename {
+		if cfg.Key == "" {
+			cfg.Key = "stdin-" + time.Now().UTC().Format("20060102-150405")
+		}
+		return false, nil
+	}
+
+	fileInfo, err := os.Stat(cfg.Filename)
+	if err != nil {
+		return false, err
+	}
+	isDir = fileInfo.IsDir()
+	if isDir && cfg.Key != "" {
+		return true, errors.New("the key [-key KEY] can only be supplied for a single file")
+	}
+
+	// the object key should not contain the local path of the file
+	if cfg.Key == "" && !isDir {
+		cfg.Key = filepath.Base(cfg.Filename)
+	}
+	return isDir, nil
+
+}
+
+func prefixKey(prefix string, key string) string {
+
+	// exactly one slash between the prefix and the key, and none at the start of the key
+
+	prefix = strings.Trim(prefix, "/")
+	key = strings.TrimLeft(key, "/")
+	if prefix == "" {
+		return key
+	}
+	return prefix + "/" + key
+
+}
+
+func (cfg *Config) source() string {
+
+	// the origin of the content for the messages: the file, the directory or the URL
+	if cfg.URL != "" {
+		return cfg.URL
+	}
+	return cfg.Filename
+
+}
+
+func (cfg *Config) newClient(ctx context.Context, res *Result) (S3API, error) {
+
+	// check the retries of the API calls
+	if cfg.MaxAttempts < 1 {
+		return nil, errors.New("the maximum number of attempts must be at least 1 [-max-attempts ATTEMPTS]")
+	}
+
+	// load the AWS configuration with the environment variables - or with the chosen profile
+	// transient errors and throttling of all S3 calls are retried with an exponential backoff
+	cfgOpts := []func(*config.LoadOptions) error{
+		config.WithRetryMaxAttempts(cfg.MaxAttempts),
+		config.WithRetryMode(aws.RetryModeAdaptive),
+	}
+	if cfg.Profile != "" {
+		cfgOpts = append(cfgOpts, config.WithSharedConfigProfile(cfg.Profile))
+	}
+	awsCfg, err := config.LoadDefaultConfig(ctx, cfgOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("load AWS configuration: %w", err)
+	}
+	// the base credentials assume the role, the cache refreshes them before they expire
+	if cfg.RoleARN != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(awsCfg), cfg.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+			if cfg.RoleSessionName != "" {
+				o.RoleSessionName = cfg.RoleSessionName
+			}
+		})
+		awsCfg.Credentials = aws.NewCredentialsCache(provider)
+	} else if cfg.RoleSessionName != "" {
+		return nil, errors.New("a role session name requires the role to assume [-role-arn ARN]")
+	}
+
+	// resolve the credentials before the first call - missing or expired credentials would fail deep inside the run
+	creds, err := awsCfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("resolve AWS credentials - set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY or [-profile PROFILE]: %w", err)
+	}
+	// only a prefix of the access key id is printed, it identifies the key without exposing it
+	keyID := creds.AccessKeyID
+	if len(keyID) > 8 {
+		keyID = keyID[:8] + "..."
+	}
+	if creds.CanExpire {
+		slog.Info("credentials resolved", "source", creds.Source, "accessKeyId", keyID, "expires", creds.Expires.UTC().Format(time.RFC3339))
+	} else {
+		slog.Info("credentials resolved", "source", creds.Source, "accessKeyId", keyID)
+	}
+
+	// set your appropriate region - an empty flag keeps the region of the AWS configuration
+	if cfg.Region != "" {
+		awsCfg.Region = cfg.Region
+	} else {
+		// the bucket creation needs the effective region for its location constraint
+		cfg.Region = awsCfg.Region
+	}
+	res.Region = awsCfg.Region
+
+	// the audit record names the AWS account of the protected objects - a custom endpoint has no AWS account
+	if cfg.ResolveAccount && cfg.Endpoint == "" {
+		identity, err := sts.NewFromConfig(awsCfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+		if err != nil {
+			slog.Warn("the AWS account of the credentials cannot be resolved", "error", apiError{err})
+		} else {
+			res.Account = aws.ToString(identity.Account)
+		}
+	}
+
+	// the service client for the next actions
+	clientOpts := func(so *s3.Options) {
+		// show the headers on the wire, e.g. if a backend drops the Object Lock headers
+		// the client of the configuration is wrapped, since it carries the settings like a CA bundle of AWS_CA_BUNDLE
+		if cfg.DebugHTTP {
+			so.HTTPClient = &debugClient{next: so.HTTPClient}
+		}
+		// show the input and latency of each API call in the debug output
+		if cfg.Verbose {
+			so.APIOptions = append(so.APIOptions, addAPICallLogging)
+		}
+		// record the duration of each API call in the result
+		if cfg.Metrics {
+			m := &callMetrics{calls: &res.Calls}
+			so.APIOptions = append(so.APIOptions, m.addAPICallMetrics)
+		}
+		// S3 compatible storages like MinIO or Ceph are usually addressed path-style
+		if cfg.Endpoint != "" {
+			so.BaseEndpoint = aws.String(cfg.Endpoint)
+			so.UsePathStyle = true
+		}
+	}
+	client := s3.NewFromConfig(awsCfg, clientOpts)
+
+	// an existing bucket of another region answers with a redirect - the client moves to the region of the bucket
+	if cfg.RegionAuto && cfg.Bucket != "" {
+		region, err := bucketRegion(ctx, client, cfg.Bucket)
+		if err != nil {
+			return nil, err
+		}
+		if region != "" && region != awsCfg.Region {
+			slog.Info("the bucket is located in another region, the client is recreated", "bucket", cfg.Bucket,
+				"configured", awsCfg.Region, "region", region)
+			awsCfg.Region, cfg.Region, res.Region = region, region, region
+			client = s3.NewFromConfig(awsCfg, clientOpts)
+		}
+	}
+	return client, nil
+
+}
@@ -0,0 +1,64 @@
+package objectlock
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Result describes the outcome of a run for the JSON output
+type Result struct {
+	// the time of the run and the AWS account with -summary-file, the region of the client
+	Timestamp             *time.Time `json:"timestamp,omitempty"`
+	Account               string     `json:"account,omitempty"`
+	Region                string     `json:"region,omitempty"`
+	Bucket                string     `json:"bucket"`
+	Created               bool       `json:"created"`
+	DefaultRetentionMode  string     `json:"defaultRetentionMode,omitempty"`
+	DefaultRetentionDays  int32      `json:"defaultRetentionDays,omitempty"`
+	DefaultRetentionYears int32      `json:"defaultRetentionYears,omitempty"`
+	// the uploaded object of a single file
+	ObjectResult
+	// the uploaded objects of a directory
+	Objects []ObjectResult `json:"objects,omitempty"`
+	Error   string         `json:"error,omitempty"`
+	// the error code and the request id of a failed S3 call
+	ErrorCode string `json:"errorCode,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+	// the replication rules of the bucket with get-replication
+	Replication []ReplicationRule `json:"replication,omitempty"`
+	// the settings of the Object Lock configuration that differ with compare-config
+	Drift []ConfigDifference `json:"drift,omitempty"`
+	// the duration of each API call with -metrics
+	Calls []APICall `json:"calls,omitempty"`
+}
+
+func formatRemaining(retainUntil time.Time, now time.Time) string {
+
+	// the remaining retention period in words, e.g. 1 day, 3 hours remaining - or EXPIRED
+
+	remaining := retainUntil.Sub(now).Round(time.Minute)
+	if !retainUntil.After(now) {
+		return "EXPIRED"
+	}
+	if remaining < time.Minute {
+		return "less than a minute remaining"
+	}
+
+	days := int(remaining / (24 * time.Hour))
+	hours := int(remaining % (24 * time.Hour) / time.Hour)
+	minutes := int(remaining % time.Hour / time.Minute)
+	var parts []string
+	for _, p := range []struct {
+		n    int
+		unit string
+	}{{days, "day"}, {hours, "hour"}, {minutes, "minute"}} {
+		if p.n == 1 {
+			parts = append(parts, "1 "+p.unit)
+		} else if p.n > 1 {
+			parts = append(parts, fmt.Sprintf("%d %ss", p.n, p.unit))
+		}
+	}
+	return strings.Join(parts, ", ") + " remaining"
+
+}
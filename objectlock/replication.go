@@ -0,0 +1,96 @@
+package objectlock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// ReplicationRule describes a replication rule of the bucket and the Object Lock of its destination
+type ReplicationRule struct {
+	ID                    string `json:"id,omitempty"`
+	Status                string `json:"status"`
+	DestinationBucket     string `json:"destinationBucket"`
+	DestinationObjectLock string `json:"destinationObjectLock"`
+	PreservesObjectLock   bool   `json:"preservesObjectLock"`
+}
+
+func checkReplication(ctx context.Context, client S3API, bucket string) ([]ReplicationRule, error) {
+
+	// S3 replicates the retention and the legal hold of an object version only into a lock-enabled destination bucket
+	// the destination of another account or region may not be readable, its Object Lock is reported as unknown then
+
+	out, err := client.GetBucketReplication(ctx, &s3.GetBucketReplicationInput{Bucket: &bucket})
+	if isNoReplication(err) {
+		slog.Info("no replication configured", "bucket", bucket)
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("get replication of %s: %w", bucket, err)
+	}
+	// an empty response body of an endpoint carries no configuration either
+	if out.ReplicationConfiguration == nil {
+		slog.Info("no replication configured", "bucket", bucket)
+		return nil, nil
+	}
+
+	var rules []ReplicationRule
+	for _, r := range out.ReplicationConfiguration.Rules {
+		rule := ReplicationRule{ID: aws.ToString(r.ID), Status: string(r.Status), DestinationObjectLock: "unknown"}
+		if r.Destination != nil {
+			rule.DestinationBucket = strings.TrimPrefix(aws.ToString(r.Destination.Bucket), "arn:aws:s3:::")
+		}
+		olc, err := getObjectLockConfiguration(ctx, client, rule.DestinationBucket)
+		switch {
+		case err != nil && !isNoObjectLockConfiguration(err):
+			slog.Warn("the Object Lock of the destination bucket cannot be read", "destination", rule.DestinationBucket, "error", apiError{err})
+		case olc != nil && olc.ObjectLockEnabled == types.ObjectLockEnabledEnabled:
+			rule.DestinationObjectLock = string(types.ObjectLockEnabledEnabled)
+		default:
+			rule.DestinationObjectLock = "NOT enabled"
+		}
+		rule.PreservesObjectLock = r.Status == types.ReplicationRuleStatusEnabled && rule.DestinationObjectLock == string(types.ObjectLockEnabledEnabled)
+		slog.Info("replication rule", "ID", rule.ID, "Status", rule.Status, "destination", rule.DestinationBucket,
+			"destinationObjectLock", rule.DestinationObjectLock)
+
+		switch {
+		case rule.PreservesObjectLock:
+			slog.Info("YES - the replication preserves the Object Lock", "ID", rule.ID, "destination", rule.DestinationBucket)
+		case r.Status != types.ReplicationRuleStatusEnabled:
+			slog.Warn("the replication rule is disabled, the locked objects are not replicated", "ID", rule.ID)
+		case rule.DestinationObjectLock == "unknown":
+			slog.Warn("the replication may not preserve the Object Lock, check the destination bucket in its account", "ID", rule.ID,
+				"destination", rule.DestinationBucket)
+		default:
+			slog.Warn("the replication does NOT preserve the Object Lock, the destination bucket is not lock-enabled", "ID", rule.ID,
+				"destination", rule.DestinationBucket)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+
+}
+
+func isNoReplication(err error) bool {
+
+	// S3 reports a bucket without replication as an error
+
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "ReplicationConfigurationNotFoundError"
+
+}
+
+func isNoObjectLockConfiguration(err error) bool {
+
+	// S3 reports a bucket without Object Lock as an error
+
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "ObjectLockConfigurationNotFoundError"
+
+}
@@ -0,0 +1,50 @@
+package objectlock
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// the limits of S3 for the tags of an object
+const (
+	maxTags           = 10
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+)
+
+func parseTags(value string) (string, error) {
+
+	// turn key1=val1,key2=val2 into the URL-encoded tag set of the Tagging header
+
+	if value == "" {
+		return "", nil
+	}
+	tags := url.Values{}
+	for _, pair := range strings.Split(value, ",") {
+		k, v, _ := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if k == "" {
+			return "", fmt.Errorf("invalid tag %q, expected key=value [-tags key1=val1,key2=val2]", pair)
+		}
+		if strings.HasPrefix(strings.ToLower(k), "aws:") {
+			return "", fmt.Errorf("the tag key %q uses the reserved prefix aws: [-tags]", k)
+		}
+		if utf8.RuneCountInString(k) > maxTagKeyLength {
+			return "", fmt.Errorf("the tag key %q is longer than %d characters [-tags]", k, maxTagKeyLength)
+		}
+		if utf8.RuneCountInString(v) > maxTagValueLength {
+			return "", fmt.Errorf("the value of the tag %q is longer than %d characters [-tags]", k, maxTagValueLength)
+		}
+		if tags.Has(k) {
+			return "", fmt.Errorf("the tag key %q is supplied more than once [-tags]", k)
+		}
+		tags.Set(k, v)
+	}
+	if len(tags) > maxTags {
+		return "", fmt.Errorf("an object can have at most %d tags, got %d [-tags]", maxTags, len(tags))
+	}
+	return tags.Encode(), nil
+
+}
@@ -0,0 +1,525 @@
+package objectlock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// lockSettings are the Object Lock parameters applied to each uploaded file
+type lockSettings struct {
+	// an empty content type is detected from the first bytes of each file
+	ContentType        string
+	ContentDisposition string
+	CacheControl       string
+	Expires            *time.Time
+	ACL                types.ObjectCannedACL
+	StorageClass       types.StorageClass
+	Tagging            string
+	Metadata           map[string]string
+	SSE                types.ServerSideEncryption
+	KMSKeyID           string
+	BucketKey          bool
+	Mode               types.ObjectLockMode
+	RetainUntil        time.Time
+	ChecksumAlgorithm  types.ChecksumAlgorithm
+	NoMD5              bool
+	MultipartThreshold int64
+	PartSize           int64
+	UploadConcurrency  int
+	LegalHold          bool
+	VerifyDownload     bool
+	Delete             bool
+	BypassGovernance   bool
+	WaitExpiry         bool
+	// the prefix of the keys of the files of a directory, the key of a single file already has it
+	KeyPrefix string
+	VerifyAPI string
+	// the lock banner of each verified object
+	Banner banner
+}
+
+// ObjectResult describes an uploaded object for the JSON output
+type ObjectResult struct {
+	Key             string            `json:"key"`
+	VersionID       string            `json:"versionId,omitempty"`
+	ObjectLockMode  string            `json:"objectLockMode,omitempty"`
+	RetainUntilDate *time.Time        `json:"retainUntilDate,omitempty"`
+	LegalHold       string            `json:"legalHold,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	Error           string            `json:"error,omitempty"`
+}
+
+// stdinFilename is the filename of the -f flag that reads the content from stdin
+const stdinFilename = "-"
+
+func bufferStdin() (string, error) {
+
+	// stdin has neither a size nor a second pass for the md5hash, so buffer it into a temporary file
+
+	return bufferContent("stdin", os.Stdin)
+
+}
+
+func bufferURL(ctx context.Context, rawURL string) (filename string, contentType string, err error) {
+
+	// download a remote artifact, e.g. of a CI artifact server, into a temporary file - like stdin
+	// the client follows up to 10 redirects, any other status than 200 OK fails the upload
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("download %s: %w", rawURL, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("download %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("download %s: the server responded with %s", rawURL, resp.Status)
+	}
+	if final := resp.Request.URL.String(); final != rawURL {
+		slog.Info("download redirected", "url", rawURL, "location", final)
+	}
+
+	filename, err = bufferContent("download", resp.Body)
+	if err != nil {
+		return "", "", err
+	}
+	slog.Info("download - success!", "url", rawURL, "ContentType", resp.Header.Get("Content-Type"))
+	return filename, resp.Header.Get("Content-Type"), nil
+
+}
+
+func bufferContent(source string, r io.Reader) (string, error) {
+
+	// a temporary file instead of memory keeps large archives like tar streams possible
+
+	tmp, err := os.CreateTemp("", "goS3ObjectLockTest-"+source+"-*")
+	if err != nil {
+		return "", fmt.Errorf("buffer %s: %w", source, err)
+	}
+	defer tmp.Close()
+	_, err = io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Close()
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("buffer %s: %w", source, err)
+	}
+	return tmp.Name(), nil
+
+}
+
+func lockFile(ctx context.Context, client S3API, bucket string, filename string, key string, ls lockSettings) (ObjectResult, error) {
+
+	// upload the file as a locked object and verify its Object Lock
+
+	or := ObjectResult{Key: key}
+
+	// prepare the upload of the file
+	file, err := os.Open(filename)
+	if err != nil {
+		return or, fmt.Errorf("open file: %w", err)
+	}
+	defer file.Close()
+
+	// Get file size - the file content is streamed and not read into a buffer
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return or, fmt.Errorf("stat file: %w", err)
+	}
+	var size int64 = fileInfo.Size()
+
+	// an empty file is uploaded as well - with ContentLength 0 and the fixed md5hash of no content
+	if size == 0 {
+		slog.Warn("the file is empty, the object is uploaded with ContentLength 0", "file", filename)
+	}
+
+	// determine the content type of your S3 object - file to be uploaded - unless it is set explicitly
+	ct := ls.ContentType
+	if ct == "" {
+		ct, err = detectContentType(file)
+		if err != nil {
+			return or, fmt.Errorf("detect content type of %s: %w", filename, err)
+		}
+	}
+
+	// create a md5hash to verify the content for the AWS file upload
+	md5h, err := getMD5Hash(file)
+	if err != nil {
+		return or, fmt.Errorf("md5hash of %s: %w", filename, err)
+	}
+
+	// the md5hash is still needed for the download verification without the Content-MD5 header
+	contentMD5 := md5h
+	if ls.NoMD5 {
+		contentMD5 = ""
+	}
+
+	// create the checksum of the content in another pass over the file, if the md5hash is not used
+	var cs string
+	if ls.ChecksumAlgorithm != "" {
+		cs, err = getChecksum(file, ls.ChecksumAlgorithm)
+		if err == nil {
+			_, err = file.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			return or, fmt.Errorf("checksum of %s: %w", filename, err)
+		}
+	}
+
+	// upload the file into the bucket - an object with the appropriate parameters
+	versionID, etag, err := uploadLockedObject(ctx, client, bucket, key, file, size, uploadOptions{
+		ContentType:          ct,
+		ContentMD5:           contentMD5,
+		ContentDisposition:   ls.ContentDisposition,
+		CacheControl:         ls.CacheControl,
+		Expires:              ls.Expires,
+		ACL:                  ls.ACL,
+		StorageClass:         ls.StorageClass,
+		Tagging:              ls.Tagging,
+		Metadata:             ls.Metadata,
+		ServerSideEncryption: ls.SSE,
+		SSEKMSKeyID:          ls.KMSKeyID,
+		BucketKeyEnabled:     ls.BucketKey,
+		ChecksumAlgorithm:    ls.ChecksumAlgorithm,
+		Checksum:             cs,
+		Mode:                 ls.Mode,
+		RetainUntil:          ls.RetainUntil,
+		MultipartThreshold:   ls.MultipartThreshold,
+		PartSize:             ls.PartSize,
+		UploadConcurrency:    ls.UploadConcurrency,
+	})
+	if err != nil {
+		switch digestError(err) {
+		case "BadDigest":
+			return or, fmt.Errorf("put object %s, the content does not match its md5hash - did the file change during the upload: %w", key, err)
+		case "InvalidDigest", "NotImplemented":
+			if ls.ChecksumAlgorithm == "" && !ls.NoMD5 {
+				return or, fmt.Errorf("put object %s, the endpoint rejects the Content-MD5 header - retry with [-no-md5] or [-checksum sha256]: %w", key, err)
+			}
+		}
+		if isACLNotSupported(err) {
+			return or, fmt.Errorf("put object %s, the Object Ownership of the bucket is BucketOwnerEnforced, which rejects ACLs - omit [-acl]: %w", key, err)
+		}
+		return or, fmt.Errorf("put object %s: %w", key, err)
+	}
+	or.VersionID = versionID
+	slog.Info("Putting of object into bucket has succeeded!", "bucket", bucket, "key", key, "size", size, "versionId", versionID)
+
+	// a cheap integrity signal - the ETag of SSE-KMS objects is no md5hash of the content
+	if etag != "" && ls.SSE != types.ServerSideEncryptionAwsKms {
+		match, err := etagMatchesMD5(etag, md5h)
+		if err != nil {
+			return or, fmt.Errorf("compare the ETag of %s: %w", key, err)
+		}
+		if !match {
+			slog.Warn("the ETag of the object differs from the md5hash of the file", "key", key, "ETag", etag, "md5hash", md5h)
+		}
+	}
+
+	// put the legal hold on the object - independent of the retention period
+	if ls.LegalHold {
+		err = setLegalHold(ctx, client, bucket, key, versionID, types.ObjectLockLegalHoldStatusOn)
+		if err != nil {
+			return or, fmt.Errorf("put legal hold of %s: %w", key, err)
+		}
+		slog.Info("PutObjectLegalHold - success!")
+	}
+
+	// request the Object Lock of the object version - with HeadObject, or with the retention and the object attributes
+	// HeadObject returns the checksum only if it verifies an uploaded one
+	var (
+		lockMode  types.ObjectLockMode
+		lockUntil *time.Time
+		metadata  middleware.Metadata
+		checksum  *types.Checksum
+		parts     bool
+	)
+	if ls.VerifyAPI == verifyAttributes {
+		outGA, err := describeAttributes(ctx, client, bucket, key, versionID)
+		if err != nil {
+			return or, err
+		}
+		ret, err := describeLock(ctx, client, bucket, key, versionID, &or, true, ls.LegalHold)
+		if err != nil {
+			return or, err
+		}
+		lockMode, lockUntil, metadata = types.ObjectLockMode(ret.Mode), ret.RetainUntilDate, outGA.ResultMetadata
+		checksum, parts = outGA.Checksum, outGA.ObjectParts != nil && outGA.ObjectParts.TotalPartsCount > 0
+		ls.Banner.printLock(ctx, lockMode, lockUntil)
+	} else {
+		outHO, err := describeObject(ctx, client, bucket, key, versionID, &or, true, ls.LegalHold, ls.checksumMode(cs != ""), ls.Banner)
+		if err != nil {
+			return or, err
+		}
+		lockMode, lockUntil, metadata = outHO.ObjectLockMode, outHO.ObjectLockRetainUntilDate, outHO.ResultMetadata
+		checksum = headObjectChecksum(outHO)
+	}
+
+	// a skewed local clock shifts the retention period - the retain until date may even be in the past for S3
+	if skew, ok := clockSkew(metadata, time.Now()); ok && (skew > maxClockSkew || skew < -maxClockSkew) {
+		slog.Warn("possible clock skew - the clock of the endpoint differs from the local clock, the retain until date is based on the local clock",
+			"skew", skew, "RetainUntilDate", ls.RetainUntil.Format(time.RFC3339))
+	}
+
+	// verify that the object is locked as requested
+	err = verifyObjectLock(lockMode, lockUntil, ls.Mode, ls.RetainUntil)
+	if err != nil {
+		return or, fmt.Errorf("object lock verification of %s failed: %w", key, err)
+	}
+	slog.Info("PASS - Object Lock verification")
+
+	// compare the checksum of the upload with the one of HeadObject or of the object attributes
+	if cs != "" {
+		err = verifyChecksum(key, ls.ChecksumAlgorithm, storedChecksum(checksum, ls.ChecksumAlgorithm), cs, parts)
+		if err != nil {
+			return or, err
+		}
+	}
+
+	// the round trip proves that the stored object is byte-identical to the file
+	if ls.VerifyDownload {
+		downloaded, err := getObjectMD5Hash(ctx, client, bucket, key, versionID)
+		if err != nil {
+			return or, fmt.Errorf("get object %s: %w", key, err)
+		}
+		if downloaded != md5h {
+			return or, fmt.Errorf("download verification of %s failed, the md5hash %s differs from the uploaded %s", key, downloaded, md5h)
+		}
+		slog.Info("PASS - download verification", "md5hash", downloaded)
+	}
+
+	// try to delete the locked object version to demonstrate the protection
+	if ls.Delete || ls.BypassGovernance {
+		err = tryDeleteObject(ctx, client, bucket, key, versionID, lockMode, lockUntil, ls.BypassGovernance)
+		if err != nil {
+			return or, err
+		}
+	}
+
+	// the end of the protection - a short retention must expire and release the object version
+	if ls.WaitExpiry {
+		err = waitForExpiry(ctx, client, bucket, key, versionID)
+		if err != nil {
+			return or, err
+		}
+	}
+
+	return or, nil
+
+}
+
+func (ls lockSettings) checksumMode(uploaded bool) types.ChecksumMode {
+
+	// HeadObject returns the checksum only for its verification - SSE-KMS objects need kms:Decrypt for it
+
+	if uploaded {
+		return types.ChecksumModeEnabled
+	}
+	return ""
+
+}
+
+func describeObject(ctx context.Context, client S3API, bucket string, key string, versionID string, or *ObjectResult,
+	requireRetention bool, requireLegalHold bool, checksumMode types.ChecksumMode, b banner) (*s3.HeadObjectOutput, error) {
+
+	// log the Object Lock of the object version - an object without a lock may report neither retention nor legal hold
+
+	// perform the request for existence of object in bucket
+	outHO, err := headObject(ctx, client, bucket, key, versionID, checksumMode)
+	if err != nil {
+		return nil, fmt.Errorf("head object %s: %w", key, err)
+	}
+	or.VersionID = aws.ToString(outHO.VersionId)
+	if outHO.ObjectLockRetainUntilDate != nil {
+		slog.Info("YES - object exists!", "bucket", bucket, "key", key, "ObjectLockMode", outHO.ObjectLockMode,
+			"ObjectLockRetainUntilDate", outHO.ObjectLockRetainUntilDate.UTC().Format(time.RFC3339),
+			"retention", formatRemaining(*outHO.ObjectLockRetainUntilDate, time.Now()))
+	} else {
+		slog.Info("YES - object exists! But there is NO retain until date <nil>", "bucket", bucket, "key", key,
+			"ObjectLockMode", outHO.ObjectLockMode)
+	}
+	b.printLock(ctx, outHO.ObjectLockMode, outHO.ObjectLockRetainUntilDate)
+	// the headers for the download of an archive
+	if outHO.ContentDisposition != nil || outHO.CacheControl != nil {
+		slog.Info("object headers", "ContentDisposition", aws.ToString(outHO.ContentDisposition),
+			"CacheControl", aws.ToString(outHO.CacheControl))
+	}
+	// S3 omits the storage class STANDARD
+	if outHO.StorageClass != "" {
+		slog.Info("object storage class", "StorageClass", outHO.StorageClass)
+	}
+	// the HTTP Expires header is often confused with the retain until date of the Object Lock
+	if outHO.Expires != nil {
+		slog.Info("object HTTP Expires header - for caches only, the Object Lock retention is the ObjectLockRetainUntilDate",
+			"Expires", outHO.Expires.UTC().Format(time.RFC3339))
+	}
+	// the metadata must have made the round trip with the object
+	if len(outHO.Metadata) > 0 {
+		or.Metadata = outHO.Metadata
+		slog.Info("object metadata", "Metadata", outHO.Metadata)
+	}
+
+	_, err = describeLock(ctx, client, bucket, key, versionID, or, requireRetention, requireLegalHold)
+	if err != nil {
+		return nil, err
+	}
+	return outHO, nil
+
+}
+
+func describeLock(ctx context.Context, client S3API, bucket string, key string, versionID string, or *ObjectResult,
+	requireRetention bool, requireLegalHold bool) (*types.ObjectLockRetention, error) {
+
+	// request the retention of the object to confirm the mode and date of the upload
+	ret, err := getObjectRetention(ctx, client, bucket, key, versionID)
+	if err != nil {
+		if requireRetention {
+			return nil, fmt.Errorf("get retention of %s: %w", key, err)
+		}
+		ret = &types.ObjectLockRetention{}
+	}
+	or.ObjectLockMode = string(ret.Mode)
+	or.RetainUntilDate = ret.RetainUntilDate
+	if ret.RetainUntilDate != nil {
+		slog.Info("object retention", "Retention.Mode", ret.Mode,
+			"Retention.RetainUntilDate", ret.RetainUntilDate.UTC().Format(time.RFC3339))
+	} else {
+		slog.Info("object retention, but there is NO retain until date <nil>", "Retention.Mode", ret.Mode)
+	}
+
+	// request the legal hold status
+	status, err := getLegalHold(ctx, client, bucket, key, versionID)
+	if err != nil {
+		if requireLegalHold {
+			return nil, fmt.Errorf("get legal hold of %s: %w", key, err)
+		}
+		status = types.ObjectLockLegalHoldStatusOff
+	}
+	or.LegalHold = string(status)
+	slog.Info("object legal hold", "ObjectLockLegalHoldStatus", status)
+
+	return ret, nil
+
+}
+
+func tryDeleteObject(ctx context.Context, client S3API, bucket string, key string, versionID string,
+	mode types.ObjectLockMode, retainUntil *time.Time, bypassGovernance bool) error {
+
+	// a privileged user may delete a GOVERNANCE object version with the bypass before its retention date,
+	// COMPLIANCE cannot be bypassed - a delete blocked by the Object Lock is no failure, but one denied by IAM is
+
+	err := deleteObjectVersion(ctx, client, bucket, key, versionID, bypassGovernance)
+	if isObjectLockProtection(err) {
+		slog.Info("DeleteObject - blocked by the Object Lock", "key", key, "versionId", versionID,
+			"ObjectLockMode", mode, "bypassGovernance", bypassGovernance)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("delete object %s: %w", key, err)
+	}
+
+	// a retained object version must not be deletable - unless the bypass lifts a GOVERNANCE retention
+	retained := retainUntil != nil && retainUntil.After(time.Now())
+	if retained && (mode == types.ObjectLockModeCompliance || mode == types.ObjectLockModeGovernance && !bypassGovernance) {
+		return fmt.Errorf("the %s object version %s of %s was deleted before its retention date %s - the endpoint does not enforce the Object Lock",
+			mode, versionID, key, retainUntil.UTC().Format(time.RFC3339))
+	}
+	slog.Info("DeleteObject - success!", "key", key, "versionId", versionID,
+		"ObjectLockMode", mode, "bypassGovernance", bypassGovernance)
+	return nil
+
+}
+
+func lockDirectory(ctx context.Context, client S3API, bucket string, dir string, ls lockSettings, concurrency int) ([]ObjectResult, error) {
+
+	// upload each regular file of the directory as a separate locked object - the relative paths become the keys
+	// a bounded pool of workers uploads the files in parallel
+
+	type task struct {
+		path string
+		key  string
+	}
+	tasks := make(chan task)
+
+	var (
+		mu      sync.Mutex
+		results []ObjectResult
+		errs    []error
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range tasks {
+				or, err := lockFile(ctx, client, bucket, t.path, t.key, ls)
+				mu.Lock()
+				if err != nil {
+					or.Error = err.Error()
+					errs = append(errs, err)
+				}
+				results = append(results, or)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	err := walkFiles(dir, ls.KeyPrefix, func(path string, key string) error {
+		tasks <- task{path: path, key: key}
+		return nil
+	})
+
+	// wait for all uploads before the summary
+	close(tasks)
+	wg.Wait()
+	sort.Slice(results, func(i, j int) bool { return results[i].Key < results[j].Key })
+	if err != nil {
+		return results, fmt.Errorf("read directory: %w", err)
+	}
+
+	// print the summary of the directory upload - the errors of the failed uploads are aggregated
+	slog.Info("directory upload summary", "directory", dir, "uploaded", len(results)-len(errs), "failed", len(errs))
+	if len(errs) > 0 {
+		return results, fmt.Errorf("%d of %d uploads failed: %w", len(errs), len(results), errors.Join(errs...))
+	}
+	return results, nil
+
+}
+
+func walkFiles(dir string, prefix string, fn func(path string, key string) error) error {
+
+	// visit each regular file of the directory with its relative path as object key, behind the optional prefix
+
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		return fn(path, prefixKey(prefix, filepath.ToSlash(rel)))
+	})
+
+}
@@ -0,0 +1,165 @@
+package objectlock
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+func TestDescribeObjectChecksumMode(t *testing.T) {
+
+	// only the verification of an uploaded checksum requests it, the status of an SSE-KMS object needs no kms:Decrypt
+
+	until := time.Date(2030, 1, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		checksumMode types.ChecksumMode
+		wantErr      bool
+	}{
+		{name: "status without checksum", checksumMode: "", wantErr: false},
+		{name: "verification of the checksum", checksumMode: types.ChecksumModeEnabled, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeS3{
+				kmsDecryptDenied: true,
+				retention:        types.ObjectLockRetention{Mode: types.ObjectLockRetentionModeGovernance, RetainUntilDate: aws.Time(until)},
+			}
+			var or ObjectResult
+			_, err := describeObject(context.Background(), client, "test-wormbucket", "iris.csv", "v1", &or, true, false,
+				tt.checksumMode, banner{w: io.Discard})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("describeObject() error = %v, want an error %t", err, tt.wantErr)
+			}
+			if len(client.headObjectArgs) != 1 || client.headObjectArgs[0].ChecksumMode != tt.checksumMode {
+				t.Errorf("HeadObject calls = %v, want one with ChecksumMode %q", client.headObjectArgs, tt.checksumMode)
+			}
+			if !tt.wantErr && or.ObjectLockMode != string(types.ObjectLockModeGovernance) {
+				t.Errorf("describeObject() ObjectLockMode = %q, want %q", or.ObjectLockMode, types.ObjectLockModeGovernance)
+			}
+		})
+	}
+
+}
+
+func TestLockFileVerifyAPI(t *testing.T) {
+
+	// the object attributes replace HeadObject, the Object Lock is verified with GetObjectRetention then
+
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	tests := []struct {
+		name           string
+		verifyAPI      string
+		wantHead       int
+		wantAttributes int
+	}{
+		{name: "head", verifyAPI: verifyHead, wantHead: 1},
+		{name: "attributes", verifyAPI: verifyAttributes, wantAttributes: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeS3{}
+			ls := lockSettings{Mode: types.ObjectLockModeGovernance, RetainUntil: until, VerifyAPI: tt.verifyAPI, Banner: banner{w: io.Discard}}
+			or, err := lockFile(context.Background(), client, "test-wormbucket", "../iris.csv", "iris.csv", ls)
+			if err != nil {
+				t.Fatalf("lockFile() error = %v", err)
+			}
+			if len(client.headObjectArgs) != tt.wantHead || len(client.attributesArgs) != tt.wantAttributes {
+				t.Errorf("lockFile() HeadObject calls = %d, GetObjectAttributes calls = %d, want %d and %d",
+					len(client.headObjectArgs), len(client.attributesArgs), tt.wantHead, tt.wantAttributes)
+			}
+			if or.ObjectLockMode != string(types.ObjectLockModeGovernance) {
+				t.Errorf("lockFile() ObjectLockMode = %q, want %q", or.ObjectLockMode, types.ObjectLockModeGovernance)
+			}
+		})
+	}
+
+}
+
+func TestLockFileEmptyFile(t *testing.T) {
+
+	// a zero-byte file is a valid object: uploaded with ContentLength 0 and the MD5 digest of no data
+
+	path := filepath.Join(t.TempDir(), "empty.txt")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	client := &fakeS3{}
+	ls := lockSettings{Mode: types.ObjectLockModeGovernance, RetainUntil: time.Now().Add(time.Hour).UTC().Truncate(time.Second),
+		VerifyAPI: verifyHead, Banner: banner{w: io.Discard}}
+	_, err := lockFile(context.Background(), client, "test-wormbucket", path, "empty.txt", ls)
+	if err != nil {
+		t.Fatalf("lockFile() error = %v", err)
+	}
+	if len(client.putObjectArgs) != 1 {
+		t.Fatalf("PutObject calls = %d, want 1", len(client.putObjectArgs))
+	}
+	input := client.putObjectArgs[0]
+	if input.ContentLength != 0 {
+		t.Errorf("PutObject ContentLength = %d, want 0", input.ContentLength)
+	}
+	if len(client.putObjectBodies[0]) != 0 {
+		t.Errorf("PutObject body has %d bytes, want none", len(client.putObjectBodies[0]))
+	}
+	if got := aws.ToString(input.ContentMD5); got != "1B2M2Y8AsgTpgAmY7PhCfg==" {
+		t.Errorf("PutObject ContentMD5 = %q, want %q", got, "1B2M2Y8AsgTpgAmY7PhCfg==")
+	}
+	if got := aws.ToString(input.ContentType); got != "application/octet-stream" {
+		t.Errorf("PutObject ContentType = %q, want %q", got, "application/octet-stream")
+	}
+	if !strings.Contains(buf.String(), "level=WARN msg=\"the file is empty") {
+		t.Errorf("lockFile() logged no warning of the empty file:\n%s", buf.String())
+	}
+
+}
+
+func TestTryDeleteObject(t *testing.T) {
+
+	// only a rejection by the Object Lock demonstrates the protection, a deleted retained version is a failure
+
+	retained := aws.Time(time.Now().Add(time.Hour))
+	expired := aws.Time(time.Now().Add(-time.Hour))
+	protected := &smithy.GenericAPIError{Code: "AccessDenied", Message: "Access Denied because object protected by object lock."}
+	worm := &smithy.GenericAPIError{Code: "InvalidRequest", Message: "Object is WORM protected and cannot be overwritten"}
+	denied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "User is not authorized to perform: s3:DeleteObjectVersion"}
+	tests := []struct {
+		name        string
+		err         error
+		mode        types.ObjectLockMode
+		retainUntil *time.Time
+		bypass      bool
+		wantErr     bool
+	}{
+		{name: "blocked by the Object Lock", err: protected, mode: types.ObjectLockModeCompliance, retainUntil: retained},
+		{name: "blocked by the WORM protection of MinIO", err: worm, mode: types.ObjectLockModeCompliance, retainUntil: retained},
+		{name: "denied by IAM", err: denied, mode: types.ObjectLockModeCompliance, retainUntil: retained, wantErr: true},
+		{name: "retained compliance version deleted", mode: types.ObjectLockModeCompliance, retainUntil: retained, wantErr: true},
+		{name: "retained governance version deleted without bypass", mode: types.ObjectLockModeGovernance, retainUntil: retained, wantErr: true},
+		{name: "retained governance version deleted with bypass", mode: types.ObjectLockModeGovernance, retainUntil: retained, bypass: true},
+		{name: "expired compliance version deleted", mode: types.ObjectLockModeCompliance, retainUntil: expired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeS3{deleteObjectErr: tt.err}
+			err := tryDeleteObject(context.Background(), client, "test-wormbucket", "iris.csv", "v1", tt.mode, tt.retainUntil, tt.bypass)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("tryDeleteObject() error = %v, want an error %t", err, tt.wantErr)
+			}
+		})
+	}
+
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/MIRIBO4630/goS3ObjectLockTest/objectlock"
+)
+
+func writeJSON(w io.Writer, res *objectlock.Result) error {
+
+	// a single JSON object, so CI can parse the result of the run
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+
+}
+
+func writeSummaryFile(path string, res *objectlock.Result) error {
+
+	// the persistent record of the run, e.g. the proof for an auditor that an object was put under WORM protection
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("write summary file: %w", err)
+	}
+	err = writeJSON(f, res)
+	err = errors.Join(err, f.Close())
+	if err != nil {
+		return fmt.Errorf("write summary file %s: %w", path, err)
+	}
+	return nil
+
+}